@@ -1,9 +1,13 @@
 package main
 
 import (
+	"bytes"
+	"io/ioutil"
 	"path"
+	"strings"
 	"testing"
 
+	"github.com/ipfs/ipfs-ds-convert/revert"
 	"github.com/ipfs/ipfs-ds-convert/testutil"
 	"os"
 )
@@ -53,3 +57,101 @@ func TestBasicCleanup(t *testing.T) {
 
 	testutil.FinishTest(t, dir, s1, s2, 200, 200)
 }
+
+func TestStatus(t *testing.T) {
+	dir, _close, s1, s2 := testutil.PrepareTest(t, 100, 100)
+	defer _close(t)
+
+	testutil.PatchConfig(t, path.Join(dir, "config"), "testfiles/badgerSpec")
+
+	os.Setenv(EnvDir, dir)
+	run([]string{".", "convert", "--keep"})
+
+	s, err := revert.GetStatus(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := new(bytes.Buffer)
+	printStatus(out, dir, s)
+
+	for _, expect := range []string{"State: done-with-backup", "Leftover files:", "datastore_spec_backup", "Next: ipfs-ds-convert cleanup"} {
+		if !strings.Contains(out.String(), expect) {
+			t.Errorf("expected '%s' in status output:\n%s", expect, out.String())
+		}
+	}
+
+	run([]string{".", "cleanup"})
+
+	s, err = revert.GetStatus(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.State != revert.StateClean || len(s.Leftovers) != 0 {
+		t.Errorf("unexpected status after cleanup %+v", s)
+	}
+
+	testutil.FinishTest(t, dir, s1, s2, 100, 100)
+}
+
+func TestConfigSetSpec(t *testing.T) {
+	dir, _close, s1, s2 := testutil.PrepareTest(t, 200, 200)
+	defer _close(t)
+
+	os.Setenv(EnvDir, dir)
+	run([]string{".", "config", "set-spec", "testfiles/badgerSpec"})
+	run([]string{".", "convert"})
+
+	if _, err := os.Stat(path.Join(dir, "badgerstore")); err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.FinishTest(t, dir, s1, s2, 200, 200)
+}
+
+func TestConvertToProfile(t *testing.T) {
+	dir, _close, s1, s2 := testutil.PrepareTest(t, 200, 200)
+	defer _close(t)
+
+	os.Setenv(EnvDir, dir)
+	run([]string{".", "convert", "--to-profile", "badgerds"})
+
+	if _, err := os.Stat(path.Join(dir, "badgerds")); err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.FinishTest(t, dir, s1, s2, 200, 200)
+}
+
+func TestConvertToSpecRevert(t *testing.T) {
+	dir, _close, s1, s2 := testutil.PrepareTest(t, 200, 200)
+	defer _close(t)
+
+	oldConfig, err := ioutil.ReadFile(path.Join(dir, "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv(EnvDir, dir)
+	run([]string{".", "convert", "--keep", "--to-spec", "testfiles/badgerSpec"})
+
+	if _, err := os.Stat(path.Join(dir, "badgerstore")); err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.FinishTest(t, dir, s1, s2, 200, 200)
+
+	run([]string{".", "revert", "--force"})
+
+	newConfig, err := ioutil.ReadFile(path.Join(dir, "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(newConfig) != string(oldConfig) {
+		t.Errorf("config was not restored by revert")
+	}
+
+	testutil.FinishTest(t, dir, s1, s2, 200, 200)
+}
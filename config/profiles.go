@@ -0,0 +1,112 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// profiles are named datastore specs, flatfs and badgerds match go-ipfs
+// profiles of the same name
+var profiles = map[string]string{
+	"flatfs": `{
+		"type": "mount",
+		"mounts": [
+			{
+				"mountpoint": "/blocks",
+				"type": "measure",
+				"prefix": "flatfs.datastore",
+				"child": {
+					"type": "flatfs",
+					"path": "blocks",
+					"sync": true,
+					"shardFunc": "/repo/flatfs/shard/v1/next-to-last/2"
+				}
+			},
+			{
+				"mountpoint": "/",
+				"type": "measure",
+				"prefix": "leveldb.datastore",
+				"child": {
+					"type": "levelds",
+					"path": "datastore",
+					"compression": "none"
+				}
+			}
+		]
+	}`,
+	"badgerds": `{
+		"type": "measure",
+		"prefix": "badger.datastore",
+		"child": {
+			"type": "badgerds",
+			"path": "badgerds",
+			"syncWrites": false,
+			"truncate": true
+		}
+	}`,
+	"leveldb-only": `{
+		"type": "measure",
+		"prefix": "leveldb.datastore",
+		"child": {
+			"type": "levelds",
+			"path": "datastore",
+			"compression": "none"
+		}
+	}`,
+	"mixed": `{
+		"type": "mount",
+		"mounts": [
+			{
+				"mountpoint": "/blocks",
+				"type": "measure",
+				"prefix": "flatfs.datastore",
+				"child": {
+					"type": "flatfs",
+					"path": "blocks",
+					"sync": true,
+					"shardFunc": "/repo/flatfs/shard/v1/next-to-last/2"
+				}
+			},
+			{
+				"mountpoint": "/",
+				"type": "measure",
+				"prefix": "badger.datastore",
+				"child": {
+					"type": "badgerds",
+					"path": "badgerds",
+					"syncWrites": false,
+					"truncate": true
+				}
+			}
+		]
+	}`,
+}
+
+// Profile returns a new copy of named datastore spec
+func Profile(name string) (map[string]interface{}, error) {
+	s, ok := profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown datastore profile '%s', available profiles: %s", name, strings.Join(ProfileNames(), ", "))
+	}
+
+	spec := make(map[string]interface{})
+	err := json.Unmarshal([]byte(s), &spec)
+	if err != nil {
+		return nil, err
+	}
+
+	return spec, nil
+}
+
+// ProfileNames returns sorted names of available profiles
+func ProfileNames() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,22 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestProfilesValid(t *testing.T) {
+	for _, name := range ProfileNames() {
+		spec, err := Profile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := Validate(spec, false); err != nil {
+			t.Errorf("profile %s: %s", name, err)
+		}
+	}
+
+	if _, err := Profile("nope"); err == nil {
+		t.Error("expected error for unknown profile")
+	}
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/pkg/errors"
+)
+
+// SetSpec sets Datastore.Spec in ipfs config file at configPath to spec. The
+// old config is copied to a backup file in the same directory first and the
+// new one is written atomically. Path of the backup is returned
+func SetSpec(configPath string, spec map[string]interface{}) (backup string, err error) {
+	backup, err = BackupFile(configPath)
+	if err != nil {
+		return "", errors.Wrapf(err, "error writing config backup")
+	}
+
+	err = WriteSpec(configPath, spec)
+	if err != nil {
+		return "", err
+	}
+
+	return backup, nil
+}
+
+// WriteSpec atomically replaces Datastore.Spec in ipfs config file at
+// configPath with spec, other config fields are kept
+func WriteSpec(configPath string, spec map[string]interface{}) error {
+	repoConfig := make(map[string]interface{})
+	err := Load(configPath, &repoConfig)
+	if err != nil {
+		return err
+	}
+
+	dsConfig, ok := repoConfig["Datastore"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("no 'Datastore' or invalid type in %s", configPath)
+	}
+
+	dsConfig["Spec"] = spec
+
+	newConfig, err := json.MarshalIndent(repoConfig, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	stat, err := os.Stat(configPath)
+	if err != nil {
+		return err
+	}
+
+	err = WriteFileAtomic(configPath, newConfig, stat.Mode().Perm())
+	if err != nil {
+		return errors.Wrapf(err, "error writing %s", configPath)
+	}
+
+	return nil
+}
+
+// BackupFile copies file at path to a new file named after it with _backup
+// suffix in the same directory and returns its path
+func BackupFile(path string) (string, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	backupFile, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+"_backup")
+	if err != nil {
+		return "", err
+	}
+	backup := backupFile.Name()
+	backupFile.Close()
+
+	err = WriteFileAtomic(backup, data, stat.Mode().Perm())
+	if err != nil {
+		os.Remove(backup)
+		return "", err
+	}
+
+	return backup, nil
+}
+
+// crashHook is called before every filesystem operation of WriteFileAtomic,
+// tests use it to simulate crashes
+var crashHook = func(op string) error {
+	return nil
+}
+
+// WriteFileAtomic writes data to a temp file next to path, syncs it and
+// renames it over path, then syncs the directory. After a crash path holds
+// either the old or the new data
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	err := crashHook("create")
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	err = crashHook("write")
+	if err == nil {
+		_, err = f.Write(data)
+	}
+	if err == nil {
+		err = f.Chmod(perm)
+	}
+	if err == nil {
+		err = crashHook("sync")
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	err = crashHook("rename")
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(f.Name(), path)
+	if err != nil {
+		return err
+	}
+
+	err = crashHook("sync-dir")
+	if err != nil {
+		return err
+	}
+
+	return SyncDir(filepath.Dir(path))
+}
+
+// SyncDir makes renames and removals in dir durable
+func SyncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		//directories can't be synced on windows
+		return nil
+	}
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	err = d.Sync()
+	if err != nil {
+		d.Close()
+		return err
+	}
+
+	return d.Close()
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomicCrash(t *testing.T) {
+	dname, err := ioutil.TempDir(os.TempDir(), "ds-convert-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dname)
+
+	p := filepath.Join(dname, "config")
+
+	noCrash := crashHook
+	defer func() {
+		crashHook = noCrash
+	}()
+
+	crashed := errors.New("simulated crash")
+
+	//crash at every filesystem operation, file must hold either the old or
+	//the new data and no temp files may be left
+	for n := 0; ; n++ {
+		crashHook = noCrash
+		if err := WriteFileAtomic(p, []byte("old"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		calls := 0
+		crashHook = func(op string) error {
+			calls++
+			if calls == n+1 {
+				return crashed
+			}
+			return nil
+		}
+
+		err := WriteFileAtomic(p, []byte("new"), 0600)
+		crashHook = noCrash
+
+		data, rerr := ioutil.ReadFile(p)
+		if rerr != nil {
+			t.Fatalf("crash %d: %s", n, rerr)
+		}
+
+		files, rerr := ioutil.ReadDir(dname)
+		if rerr != nil {
+			t.Fatal(rerr)
+		}
+		if len(files) != 1 {
+			t.Fatalf("crash %d: unexpected files: %d", n, len(files))
+		}
+
+		if err == nil {
+			if n == 0 {
+				t.Fatal("crash hook was never called")
+			}
+			if string(data) != "new" {
+				t.Fatalf("unexpected data '%s'", data)
+			}
+			break
+		}
+		if err != crashed {
+			t.Fatal(err)
+		}
+
+		if string(data) != "old" && string(data) != "new" {
+			t.Fatalf("crash %d: unexpected data '%s'", n, data)
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"path/filepath"
+
+	humanize "github.com/dustin/go-humanize"
 )
 
 var (
@@ -19,6 +21,7 @@ var validators = map[string]func(*validatorContext, map[string]interface{}) erro
 
 func init() {
 	validators["badgerds"] = badgerdsValidator
+	validators["badger2ds"] = badger2dsValidator
 	validators["flatfs"] = flatfsValidator
 	validators["levelds"] = leveldsValidator
 	validators["log"] = logValidator
@@ -130,6 +133,48 @@ func badgerdsValidator(ctx *validatorContext, dsConfiguration map[string]interfa
 	return nil
 }
 
+func badger2dsValidator(ctx *validatorContext, dsConfiguration map[string]interface{}) error {
+	err := checkPath(ctx, dsConfiguration["path"])
+	if err != nil {
+		return err
+	}
+
+	for _, name := range []string{"syncWrites", "truncate"} {
+		_, ok := dsConfiguration[name]
+		if !ok && ctx.fillDefault {
+			dsConfiguration[name] = true
+		} else if ok {
+			_, ok := dsConfiguration[name].(bool)
+			if !ok {
+				return fmt.Errorf("invalid %s field type in badger spec", name)
+			}
+		}
+	}
+
+	if v, ok := dsConfiguration["vlogFileSize"]; ok {
+		size, ok := v.(string)
+		if !ok {
+			return errors.New("invalid vlogFileSize field type in badger spec")
+		}
+		if _, err := humanize.ParseBytes(size); err != nil {
+			return fmt.Errorf("invalid vlogFileSize field in badger spec: %s", err)
+		}
+	}
+
+	_, ok := dsConfiguration["compression"]
+	if !ok && ctx.fillDefault {
+		dsConfiguration["compression"] = "none"
+	} else if ok {
+		switch dsConfiguration["compression"] {
+		case "none", "snappy", "zstd":
+		default:
+			return errors.New("invalid compression field in badger spec, expected none, snappy or zstd")
+		}
+	}
+
+	return nil
+}
+
 func mountValidator(ctx *validatorContext, dsConfiguration map[string]interface{}) error {
 	mounts, ok := dsConfiguration["mounts"].([]interface{})
 	if !ok {
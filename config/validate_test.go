@@ -72,6 +72,24 @@ var (
 		"compression": 2,
 	}
 
+	Badger2dsSpec = map[string]interface{}{
+		"type":         "badger2ds",
+		"path":         "badger2ds",
+		"vlogFileSize": "1GiB",
+	}
+
+	Badger2dsInvalidCompression = map[string]interface{}{
+		"type":        "badger2ds",
+		"path":        "badger2ds",
+		"compression": "lz4",
+	}
+
+	Badger2dsInvalidVlogSize = map[string]interface{}{
+		"type":         "badger2ds",
+		"path":         "badger2ds",
+		"vlogFileSize": "lots",
+	}
+
 	MountlessMount = map[string]interface{}{
 		"type": "mount",
 	}
@@ -258,6 +276,27 @@ func TestLeveldbNumSpec(t *testing.T) {
 	t.Errorf("expected error")
 }
 
+func TestBadger2dsSpec(t *testing.T) {
+	_, err := Validate(Badger2dsSpec, true)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	if Badger2dsSpec["syncWrites"] != true || Badger2dsSpec["truncate"] != true || Badger2dsSpec["compression"] != "none" {
+		t.Errorf("default fields not injected to badger spec: %v", Badger2dsSpec)
+	}
+
+	_, err = Validate(Badger2dsInvalidCompression, false)
+	if err == nil || !strings.Contains(err.Error(), "invalid compression field in badger spec") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	_, err = Validate(Badger2dsInvalidVlogSize, false)
+	if err == nil || !strings.Contains(err.Error(), "invalid vlogFileSize field in badger spec") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
 func TestMountlessMountSpec(t *testing.T) {
 	_, err := Validate(MountlessMount, false)
 	if err != nil {
@@ -1,8 +1,11 @@
 package convert_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"os"
 	"path"
 	"runtime"
@@ -38,6 +41,60 @@ func TestInvalidRepoVersion(t *testing.T) {
 	}
 }
 
+func TestRepoVersions(t *testing.T) {
+	cases := []struct {
+		version string
+		err     string
+	}{
+		{version: "6"},
+		{version: "12"},
+		{version: "15"},
+		{version: "5", err: "unsupported fsrepo version: 5, supported versions are 6 to 15. Migrate the repo with 'fs-repo-migrations -to 6'"},
+		{version: "16", err: "unsupported fsrepo version: 16, supported versions are 6 to 15. Use newer ipfs-ds-convert or revert the repo with 'fs-repo-migrations -to 15 -revert-ok'"},
+	}
+
+	for _, c := range cases {
+		dir, _close, _, _ := testutil.PrepareTest(t, 10, 10)
+
+		err := ioutil.WriteFile(path.Join(dir, "version"), []byte(c.version+"\n"), 0664)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		testutil.PatchConfig(t, path.Join(dir, "config"), "../testfiles/badgerSpec")
+
+		err = convert.Convert(dir, false)
+		if c.err == "" && err != nil {
+			t.Errorf("version %s: %s", c.version, err)
+		}
+		if c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)) {
+			t.Errorf("version %s: unexpected error %v", c.version, err)
+		}
+
+		_close(t)
+	}
+}
+
+func TestInvalidToSpec(t *testing.T) {
+	//Prepare repo
+	dir, _close, _, _ := testutil.PrepareTest(t, 10, 10)
+	defer _close(t)
+
+	spec := map[string]interface{}{
+		"type": "notAValidDatastoreType",
+		"path": "notADir",
+	}
+
+	err := convert.ConvertWithOptions(context.Background(), dir, convert.Options{ToSpec: spec})
+	if err == nil || !strings.Contains(err.Error(), "unsupported type entry in config: notAValidDatastoreType") {
+		t.Fatal(fmt.Errorf("unexpected error: %v", err))
+	}
+
+	if _, err := os.Stat(path.Join(dir, revert.ConvertLog)); !os.IsNotExist(err) {
+		t.Errorf("expected no %s, got %v", revert.ConvertLog, err)
+	}
+}
+
 func TestLockedRepo(t *testing.T) {
 	//Prepare repo
 	dir, _close, _, _ := testutil.PrepareTest(t, 10, 10)
@@ -60,6 +117,80 @@ func TestLockedRepo(t *testing.T) {
 	}
 }
 
+func TestDaemonRunning(t *testing.T) {
+	//Prepare repo
+	dir, _close, _, _ := testutil.PrepareTest(t, 10, 10)
+	defer _close(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	port := l.Addr().(*net.TCPAddr).Port
+	err = ioutil.WriteFile(path.Join(dir, repo.ApiFile), []byte(fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", port)), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = convert.Convert(dir, false)
+	var daemon *repo.DaemonRunningError
+	if !errors.As(err, &daemon) || !strings.Contains(err.Error(), "accepts connections") {
+		t.Fatal(fmt.Errorf("unexpected error: %v", err))
+	}
+
+	//api file left by a daemon which is not running anymore
+	l.Close()
+
+	err = convert.Convert(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStaleLock(t *testing.T) {
+	//Prepare repo
+	dir, _close, _, _ := testutil.PrepareTest(t, 10, 10)
+	defer _close(t)
+
+	//pid above linux pid_max, there is no such process
+	err := ioutil.WriteFile(path.Join(dir, repo.LockFile), []byte(`{"OwnerPID":99999999}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = convert.Convert(dir, false)
+	var stale *repo.StaleLockError
+	if !errors.As(err, &stale) || !strings.Contains(err.Error(), "--break-lock") {
+		t.Fatal(fmt.Errorf("unexpected error: %v", err))
+	}
+
+	if _, err := os.Stat(path.Join(dir, revert.ConvertLog)); !os.IsNotExist(err) {
+		t.Fatalf("convertlog created with stale lock: %v", err)
+	}
+
+	err = ioutil.WriteFile(path.Join(dir, repo.LockFile), []byte(fmt.Sprintf(`{"OwnerPID":%d}`, os.Getpid())), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = convert.Convert(dir, false)
+	var daemon *repo.DaemonRunningError
+	if !errors.As(err, &daemon) {
+		t.Fatal(fmt.Errorf("unexpected error: %v", err))
+	}
+
+	err = ioutil.WriteFile(path.Join(dir, repo.LockFile), []byte(`{"OwnerPID":99999999}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = convert.ConvertWithOptions(context.Background(), dir, convert.Options{BreakLock: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestNoSpec(t *testing.T) {
 	//Prepare repo
 	dir, _close, _, _ := testutil.PrepareTest(t, 10, 10)
@@ -1,22 +1,30 @@
 package convert
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"time"
 
 	logging "log"
 
+	"github.com/ipfs/ipfs-ds-convert/config"
 	"github.com/ipfs/ipfs-ds-convert/repo"
 	"github.com/ipfs/ipfs-ds-convert/revert"
 	"github.com/ipfs/ipfs-ds-convert/strategy"
 
-	lock "github.com/ipfs/go-fs-lock"
+	errors "github.com/pkg/errors"
 )
 
 var Log = logging.New(os.Stderr, "convert ", logging.LstdFlags)
 
+// ErrInterrupted is returned when conversion context was cancelled. Datastores
+// are closed and convertlog is up to date, so the conversion can be reverted,
+// or resumed when it was interrupted while copying keys
+var ErrInterrupted = errors.New("conversion interrupted")
+
 // Conversion holds Conversion state and progress
 type Conversion struct {
 	steps []string
@@ -24,14 +32,81 @@ type Conversion struct {
 
 	path string
 
+	//locked is set once the repo lock was taken
+	locked bool
+
 	fromSpec map[string]interface{}
 	toSpec   map[string]interface{}
+
+	//Datastore.Spec in repo config when conversion started
+	configSpec map[string]interface{}
+
+	//config backups restored by revert
+	configBackups []string
+
+	opts   Options
+	result Result
+}
+
+// Options tune how conversion is run
+type Options struct {
+	// KeepBackup leaves old datastore and spec in the repo, see 'cleanup'
+	KeepBackup bool
+
+	// Resume continues copy phase of interrupted conversion from the last
+	// checkpoint in convertlog
+	Resume bool
+
+	// Workers is the number of goroutines reading keys from the old datastore
+	// while copying, defaults to 1
+	Workers int
+
+	// Verify selects how copied data is checked, see ParseVerifyMode
+	Verify VerifyMode
+
+	// IgnoreSpace skips checking free disk space before copying
+	IgnoreSpace bool
+
+	// BreakLock removes repo lock left by a program which is no longer
+	// running, see repo.StaleLockError
+	BreakLock bool
+
+	// RenameMounts keeps data of mounts whose mountpoint changed in place,
+	// keys of such mounts get the new prefix instead of being copied
+	RenameMounts bool
+
+	// ToSpec is the spec to convert to instead of Datastore.Spec in repo
+	// config. It's written into config together with datastore_spec when
+	// conversion finishes, see config.Profile for built-in specs
+	ToSpec map[string]interface{}
+
+	// Logger receives conversion log messages, defaults to Log
+	Logger Logger
+
+	// Report is the path conversion report is written to when conversion
+	// ends, successfully or not. No report is written when empty
+	Report string
+
+	// Progress receives events as conversion phases start, finish and make
+	// progress. When nil, progress is printed to stdout, see TextProgress
+	Progress func(Event)
 }
 
 func Convert(repoPath string, keepBackup bool) error {
-	c := Conversion{
-		path: repoPath,
-	}
+	return ConvertWithOptions(context.Background(), repoPath, Options{KeepBackup: keepBackup})
+}
+
+// ConvertWithOptions converts datastore of the repo at repoPath to the spec
+// in repo config. When ctx is cancelled, the running step stops at the next
+// safe point and ErrInterrupted is returned
+func ConvertWithOptions(ctx context.Context, repoPath string, opts Options) error {
+	_, err := NewConverter(opts).Convert(ctx, repoPath)
+	return err
+}
+
+func (c *Conversion) run(ctx context.Context) error {
+	keepBackup := c.opts.KeepBackup
+	log := c.opts.logger()
 
 	c.addStep("begin with tool version %s", repo.ToolVersion)
 
@@ -40,13 +115,33 @@ func Convert(repoPath string, keepBackup bool) error {
 		return err
 	}
 
-	unlock, err := lock.Lock(c.path, repo.LockFile)
+	//invalid target spec is rejected before convertlog is created, so that
+	//nothing is left in the repo to revert
+	if c.opts.ToSpec != nil {
+		_, err = config.Validate(c.opts.ToSpec, false)
+		if err != nil {
+			return errors.Wrapf(err, "validating new spec")
+		}
+	}
+
+	unlock, err := lockRepo(c.path, c.opts.BreakLock, log)
 	if err != nil {
 		return err
 	}
 	defer unlock.Close()
+	c.locked = true
 
-	c.log, err = revert.NewActionLogger(c.path)
+	var resume *revert.Checkpoint
+	if c.opts.Resume {
+		resume, err = revert.LoadCheckpoint(c.path)
+		if err != nil {
+			return err
+		}
+
+		c.log, err = revert.OpenActionLogger(c.path)
+	} else {
+		c.log, err = revert.NewActionLogger(c.path)
+	}
 	if err != nil {
 		return err
 	}
@@ -57,46 +152,60 @@ func Convert(repoPath string, keepBackup bool) error {
 		return err
 	}
 
-	s, err := strategy.NewStrategy(c.fromSpec, c.toSpec)
+	s, err := strategy.NewStrategyWithOptions(c.fromSpec, c.toSpec, strategy.Options{RenameMounts: c.opts.RenameMounts})
 	if err != nil {
 		return c.wrapErr(err)
 	}
 
 	strat := s.Spec()
-	conversionType, _ := strat.Type()
-	switch conversionType {
-	case "copy":
-		from, _ := strat.Sub("from")
-		to, _ := strat.Sub("to")
+	c.result.Strategy = strat
+	c.result.StrategyId = s.Id()
+
+	if resume != nil && (!hasCopy(strat) || (resume.Strategy != "" && resume.Strategy != s.Id())) {
+		return c.wrapErr(errors.New("conversion strategy changed, can't resume"))
+	}
 
-		copy := NewCopy(c.path, from, to, c.log, c.addStep)
-		err := copy.Run()
+	if resume == nil {
+		err = c.log.SetStrategy(s.Id())
 		if err != nil {
 			return c.wrapErr(err)
 		}
+	}
 
-		err = copy.Verify()
+	if resume != nil {
+		c.configBackups = resume.Backups
+	} else {
+		err = c.backupConfig()
 		if err != nil {
 			return c.wrapErr(err)
 		}
+	}
 
-		if !keepBackup {
-			err = copy.Clean()
-			if err != nil {
-				return c.wrapErr(err)
-			}
-		}
-	case "noop":
-	default:
-		panic(fmt.Sprintf("unexpected strategy %s", conversionType))
+	err = c.runStrategy(ctx, strat, resume)
+	if errors.Is(err, ErrInterrupted) {
+		return err
+	}
+	if err != nil {
+		return c.wrapErr(err)
+	}
+
+	if ctx.Err() != nil {
+		return ErrInterrupted
 	}
 
-	Log.Println("Saving new spec")
+	log.Printf("Saving new spec")
 	err = c.saveNewSpec(keepBackup)
 	if err != nil {
 		return c.wrapErr(err)
 	}
 
+	if c.opts.ToSpec != nil {
+		err = config.WriteSpec(filepath.Join(c.path, repo.ConfigFile), c.toSpec)
+		if err != nil {
+			return c.wrapErr(err)
+		}
+	}
+
 	c.log.Log(revert.ActionDone)
 
 	if !keepBackup {
@@ -104,18 +213,127 @@ func Convert(repoPath string, keepBackup bool) error {
 		if err != nil {
 			return err
 		}
+
+		for _, backup := range c.configBackups {
+			err = os.Remove(backup)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	if keepBackup {
-		Log.Println(">>            Backup files were not removed            <<")
-		Log.Println(">> To revert to previous state run 'revert' subcommand <<")
-		Log.Println(">>   To remove backup files run 'cleanup' subcommand   <<")
+		log.Printf(">>            Backup files were not removed            <<")
+		log.Printf(">> To revert to previous state run 'revert' subcommand <<")
+		log.Printf(">>   To remove backup files run 'cleanup' subcommand   <<")
+	}
+
+	log.Printf("All tasks finished")
+	return nil
+}
+
+func (c *Conversion) runStrategy(ctx context.Context, strat strategy.Spec, resume *revert.Checkpoint) error {
+	opts := c.opts
+
+	if ctx.Err() != nil {
+		return ErrInterrupted
+	}
+
+	conversionType, _ := strat.Type()
+	switch conversionType {
+	case "copy":
+		from, _ := strat.Sub("from")
+		to, _ := strat.Sub("to")
+
+		copy := NewCopy(c.path, from, to, opts, c.log, c.addStep)
+
+		start := time.Now()
+
+		var err error
+		if resume != nil {
+			err = copy.Resume(ctx, resume)
+		} else {
+			err = copy.Run(ctx)
+		}
+
+		c.result.KeysCopied += copy.copied
+		c.result.BytesCopied += copy.copiedBytes
+		c.result.CopyDuration += time.Since(start)
+		if err != nil {
+			c.result.Mounts = append(c.result.Mounts, copy.sortedMountStats()...)
+			return err
+		}
+
+		start = time.Now()
+		err = copy.Verify(ctx, opts.Verify)
+		c.result.KeysVerified += copy.verified
+		c.result.VerifyDuration += time.Since(start)
+		c.result.Mounts = append(c.result.Mounts, copy.sortedMountStats()...)
+		if err != nil {
+			return err
+		}
+
+		//old datastore is removed only if conversion wasn't interrupted
+		if ctx.Err() != nil {
+			return ErrInterrupted
+		}
+
+		if !opts.KeepBackup {
+			return copy.Clean()
+		}
+
+		c.result.Backups = append(c.result.Backups, copy.oldDsDir)
+	case "move":
+		moves, _ := strat.Moves()
+
+		ph := opts.startPhase("move")
+		err := NewMove(c.path, moves, opts, c.log, c.addStep).Run()
+		ph.finish(0, 0, err)
+		return err
+	case "rename":
+		renames, _ := strat.Renames()
+
+		ph := opts.startPhase("rename")
+		err := NewRename(c.path, c.fromSpec, renames, opts, c.addStep).Run()
+		ph.finish(0, 0, err)
+		return err
+	case "reshard":
+		reshards, _ := strat.Reshards()
+
+		return NewReshard(c.path, reshards, opts, c.log, c.addStep).Run()
+	case "multi":
+		steps, _ := strat.Steps()
+
+		for _, step := range steps {
+			err := c.runStrategy(ctx, step, resume)
+			if err != nil {
+				return err
+			}
+		}
+	case "noop":
+	default:
+		panic(fmt.Sprintf("unexpected strategy %s", conversionType))
 	}
 
-	Log.Println("All tasks finished")
 	return nil
 }
 
+// hasCopy returns true if strategy copies data, only such conversions can be
+// resumed
+func hasCopy(strat strategy.Spec) bool {
+	t, _ := strat.Type()
+	if t == "multi" {
+		steps, _ := strat.Steps()
+		for _, step := range steps {
+			if hasCopy(step) {
+				return true
+			}
+		}
+	}
+
+	return t == "copy"
+}
+
 func (c *Conversion) saveNewSpec(backup bool) (err error) {
 
 	if backup {
@@ -150,6 +368,50 @@ func (c *Conversion) saveNewSpec(backup bool) (err error) {
 	return nil
 }
 
+// backupConfig saves a copy of repo config with Datastore.Spec matching the
+// old datastore_spec and logs restoring it first, so that after revert config
+// and datastore_spec agree even when Datastore.Spec was edited before
+// conversion
+func (c *Conversion) backupConfig() error {
+	configPath := filepath.Join(c.path, repo.ConfigFile)
+
+	backup, err := config.BackupFile(configPath)
+	if err != nil {
+		return errors.Wrapf(err, "error creating backup of %s", configPath)
+	}
+
+	configId, err := repo.DatastoreSpec(c.configSpec)
+	if err != nil {
+		configId = ""
+	}
+
+	fromId, err := repo.DatastoreSpec(c.fromSpec)
+	if err != nil {
+		return err
+	}
+
+	//spec in config differs in measure wrappers and other fields not stored
+	//in datastore_spec, keep it as is when it describes the same datastore
+	if configId != fromId {
+		err = config.WriteSpec(backup, c.fromSpec)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.log.Log(revert.ActionRestore, backup, configPath)
+	if err != nil {
+		return err
+	}
+
+	c.configBackups = append(c.configBackups, backup)
+	if c.opts.KeepBackup {
+		c.result.Backups = append(c.result.Backups, backup)
+	}
+
+	return nil
+}
+
 func (c *Conversion) backupSpec() error {
 	backupFile, err := ioutil.TempFile(c.path, "datastore_spec_backup")
 	if err != nil {
@@ -179,6 +441,7 @@ func (c *Conversion) backupSpec() error {
 	if err != nil {
 		return err
 	}
+	c.result.Backups = append(c.result.Backups, backupFile.Name())
 
 	err = backupFile.Close()
 	if err != nil {
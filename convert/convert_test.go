@@ -1,7 +1,10 @@
 package convert_test
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"path"
 	"strings"
 	"testing"
@@ -28,6 +31,46 @@ func TestBasicConvert(t *testing.T) {
 	testutil.FinishTest(t, dir, s1, s2, 3000, 3000)
 }
 
+func TestParallelConvert(t *testing.T) {
+	//Prepare repo
+	dir, _close, s1, s2 := testutil.PrepareTest(t, 3000, 3000)
+	defer _close(t)
+
+	testutil.PatchConfig(t, path.Join(dir, "config"), "../testfiles/badgerSpec")
+
+	//Convert!
+	err := convert.ConvertWithOptions(context.Background(), dir, convert.Options{Workers: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.FinishTest(t, dir, s1, s2, 3000, 3000)
+}
+
+func TestBadger2Convert(t *testing.T) {
+	//Prepare repo
+	dir, _close, s1, s2 := testutil.PrepareTest(t, 1000, 1000)
+	defer _close(t)
+
+	testutil.PatchConfig(t, path.Join(dir, "config"), "../testfiles/badger2Spec")
+
+	//Convert to badger2
+	err := convert.Convert(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//go-ipfs can't open badger2ds, convert back to badger to verify keys
+	testutil.PatchConfig(t, path.Join(dir, "config"), "../testfiles/badgerSpec")
+
+	err = convert.Convert(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.FinishTest(t, dir, s1, s2, 1000, 1000)
+}
+
 func TestLossyConvert(t *testing.T) {
 	//Prepare repo
 	dir, _close, _, _ := testutil.PrepareTest(t, 100, 100)
@@ -120,3 +163,213 @@ func TestSkipCopyConvert(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestMoveConvert(t *testing.T) {
+	spec := make(map[string]interface{})
+	err := config.Load("../testfiles/levelSpec", &spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, _close := testutil.NewTestRepo(t, spec)
+	defer _close(t)
+
+	r, err := testutil.OpenRepo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seed, err := testutil.InsertRandomKeys("", 1000, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.PatchConfig(t, path.Join(dir, "config"), "../testfiles/levelMovedSpec")
+
+	err = convert.Convert(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(path.Join(dir, "datastore")); !os.IsNotExist(err) {
+		t.Errorf("expected old datastore directory to be moved, got %v", err)
+	}
+
+	if s, err := os.Stat(path.Join(dir, "leveldb")); err != nil || !s.IsDir() {
+		t.Errorf("expected datastore to be moved to 'leveldb', got %v", err)
+	}
+
+	r, err = testutil.OpenRepo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = testutil.Verify("", 1000, seed, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRenameMountsConvert(t *testing.T) {
+	spec := make(map[string]interface{})
+	err := config.Load("../testfiles/skipableSpec", &spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, _close := testutil.NewTestRepo(t, spec)
+	defer _close(t)
+
+	r, err := testutil.OpenRepo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prefixes := []string{"a/", "b/", "c/", "e/"}
+	seeds := []int64{}
+
+	for _, prefix := range prefixes {
+		seed, err := testutil.InsertRandomKeys(prefix, 1000, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seeds = append(seeds, seed)
+	}
+
+	err = r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.PatchConfig(t, path.Join(dir, "config"), "../testfiles/skipableDstSpec")
+
+	err = convert.ConvertWithOptions(context.Background(), dir, convert.Options{RenameMounts: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err = testutil.OpenRepo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//keys from /c are now under /d
+	prefixes[2] = "d/"
+
+	for i, prefix := range prefixes {
+		err = testutil.Verify(prefix, 1000, seeds[i], r)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRenameMountsHiddenKeys(t *testing.T) {
+	spec := make(map[string]interface{})
+	err := config.Load("../testfiles/skipableSpec", &spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, _close := testutil.NewTestRepo(t, spec)
+	defer _close(t)
+
+	r, err := testutil.OpenRepo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = testutil.InsertRandomKeys("d/", 10, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.PatchConfig(t, path.Join(dir, "config"), "../testfiles/skipableDstSpec")
+
+	err = convert.ConvertWithOptions(context.Background(), dir, convert.Options{RenameMounts: true})
+	if err == nil || !strings.Contains(err.Error(), "can't rename mount /c to /d, old datastore has keys under /d") {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestReshardConvert(t *testing.T) {
+	//Prepare repo
+	dir, _close, s1, s2 := testutil.PrepareTest(t, 1000, 1000)
+	defer _close(t)
+
+	testutil.PatchConfig(t, path.Join(dir, "config"), "../testfiles/reshardSpec")
+
+	err := convert.Convert(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sharding, err := ioutil.ReadFile(path.Join(dir, "blocks", "SHARDING"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.TrimSpace(string(sharding)) != "/repo/flatfs/shard/v1/prefix/4" {
+		t.Errorf("unexpected SHARDING file content: %s", sharding)
+	}
+
+	testutil.FinishTest(t, dir, s1, s2, 1000, 1000)
+}
+
+func TestToSpecConvert(t *testing.T) {
+	//Prepare repo
+	dir, _close, s1, s2 := testutil.PrepareTest(t, 1000, 1000)
+	defer _close(t)
+
+	spec, err := config.Profile("mixed")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//Convert without touching config first
+	err = convert.ConvertWithOptions(context.Background(), dir, convert.Options{ToSpec: spec})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repoConfig := make(map[string]interface{})
+	if err := config.Load(path.Join(dir, "config"), &repoConfig); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := repoConfig["Datastore"].(map[string]interface{})["Spec"].(map[string]interface{})["mounts"]; !ok {
+		t.Errorf("unexpected spec in config: %v", repoConfig["Datastore"])
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if strings.HasPrefix(f.Name(), "config_backup") {
+			t.Errorf("config backup %s was not removed", f.Name())
+		}
+	}
+
+	testutil.FinishTest(t, dir, s1, s2, 1000, 1000)
+}
@@ -0,0 +1,148 @@
+package convert
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/ipfs/ipfs-ds-convert/strategy"
+
+	errors "github.com/pkg/errors"
+)
+
+// Logger is the interface conversion log messages are written to, it's
+// satisfied by *log.Logger
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Result describes what a conversion did. It's returned also when conversion
+// fails, with work done until the failure
+type Result struct {
+	// Strategy is the conversion strategy used, nil when conversion failed
+	// before it was computed
+	Strategy strategy.Spec
+
+	// StrategyId identifies the strategy, see strategy.Strategy.Id
+	StrategyId string
+
+	// KeysCopied is the number of keys in the new datastore after copying,
+	// including keys found already copied when resuming
+	KeysCopied int
+
+	// BytesCopied is the size of values written to the new datastore
+	BytesCopied int64
+
+	// KeysVerified is the number of keys checked after copying
+	KeysVerified int
+
+	// Mounts holds per mount stats of copied mounts, sorted by mountpoint
+	Mounts []MountStats
+
+	// Phases lists finished phases in the order they ran
+	Phases []PhaseStats
+
+	// Backups are paths of old datastore and spec left in the repo when
+	// conversion ran with KeepBackup
+	Backups []string
+
+	// ReportError is set when writing Options.Report failed. It doesn't fail
+	// the conversion, the repo is converted already at that point
+	ReportError error
+
+	Duration       time.Duration
+	CopyDuration   time.Duration
+	VerifyDuration time.Duration
+}
+
+// MountStats describes data copied to and verified in a single mount
+type MountStats struct {
+	Mountpoint string `json:"mountpoint"`
+
+	// KeysCopied and BytesCopied count keys written in this run, keys found
+	// already copied when resuming are not included
+	KeysCopied  int   `json:"keys_copied"`
+	BytesCopied int64 `json:"bytes_copied"`
+
+	// KeysVerified is the number of keys of the old datastore found in the
+	// mount, KeysInNew number of all keys in the mount after copying and
+	// ExtraKeys number of keys which were not in the old datastore
+	KeysVerified int `json:"keys_verified"`
+	KeysInNew    int `json:"keys_in_new"`
+	ExtraKeys    int `json:"extra_keys"`
+}
+
+// PhaseStats describes a finished conversion phase, see EventPhaseFinished
+type PhaseStats struct {
+	Phase    string
+	Keys     int
+	Bytes    int64
+	Duration time.Duration
+
+	// Error is set when the phase failed
+	Error string
+}
+
+// Converter runs datastore conversions of ipfs repos in-process
+type Converter struct {
+	opts Options
+}
+
+func NewConverter(opts Options) *Converter {
+	return &Converter{
+		opts: opts,
+	}
+}
+
+// Convert converts datastore of the repo at repoPath to the spec in repo
+// config. When ctx is cancelled, the running step stops at the next safe point
+// and ErrInterrupted is returned
+func (cv *Converter) Convert(ctx context.Context, repoPath string) (*Result, error) {
+	c := Conversion{
+		path: repoPath,
+		opts: cv.opts,
+	}
+
+	progress := c.opts.Progress
+	if progress == nil {
+		progress = TextProgress(os.Stdout)
+	}
+
+	c.opts.Progress = func(e Event) {
+		if e.Type == EventPhaseFinished {
+			c.result.Phases = append(c.result.Phases, PhaseStats{
+				Phase:    e.Phase,
+				Keys:     e.Keys,
+				Bytes:    e.Bytes,
+				Duration: e.Elapsed,
+				Error:    e.Error,
+			})
+		}
+		progress(e)
+	}
+
+	start := time.Now()
+	err := c.run(ctx)
+	c.result.Duration = time.Since(start)
+
+	//nothing was done to a repo which couldn't be locked, possibly because
+	//daemon is using it, don't write into it
+	if c.opts.Report != "" && c.locked {
+		rerr := WriteReport(c.opts.Report, c.report(start, err))
+		if rerr != nil {
+			c.result.ReportError = errors.Wrapf(rerr, "writing report to %s failed", c.opts.Report)
+			c.opts.logger().Printf("Warning: %s", c.result.ReportError)
+		} else {
+			c.opts.logger().Printf("Conversion report written to %s", c.opts.Report)
+		}
+	}
+
+	return &c.result, err
+}
+
+func (o *Options) logger() Logger {
+	if o.Logger != nil {
+		return o.Logger
+	}
+	return Log
+}
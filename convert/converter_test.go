@@ -0,0 +1,229 @@
+package convert_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/ipfs-ds-convert/convert"
+	"github.com/ipfs/ipfs-ds-convert/repo"
+	"github.com/ipfs/ipfs-ds-convert/testutil"
+
+	lock "github.com/ipfs/go-fs-lock"
+)
+
+func TestConverterResult(t *testing.T) {
+	//Prepare repo
+	dir, _close, s1, s2 := testutil.PrepareTest(t, 100, 100)
+	defer _close(t)
+
+	testutil.PatchConfig(t, path.Join(dir, "config"), "../testfiles/badgerSpec")
+
+	logs := new(bytes.Buffer)
+	finished := map[string]convert.Event{}
+	var batches, steps int
+
+	res, err := convert.NewConverter(convert.Options{
+		Workers: 2,
+		Verify:  convert.VerifyMode{Values: true},
+		Logger:  log.New(logs, "", 0),
+		Progress: func(e convert.Event) {
+			switch e.Type {
+			case convert.EventPhaseFinished:
+				finished[e.Phase] = e
+			case convert.EventBatchCommitted:
+				batches++
+			case convert.EventStep:
+				steps++
+			}
+		},
+	}).Convert(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if typ, _ := res.Strategy.Type(); typ != "copy" {
+		t.Errorf("unexpected strategy %s", typ)
+	}
+
+	if res.KeysCopied < 101 || res.KeysVerified != res.KeysCopied {
+		t.Errorf("unexpected key counts: %d copied, %d verified", res.KeysCopied, res.KeysVerified)
+	}
+
+	if res.BytesCopied < 100*1024 {
+		t.Errorf("unexpected byte count %d", res.BytesCopied)
+	}
+
+	if res.Duration <= 0 || res.CopyDuration <= 0 || res.VerifyDuration <= 0 || res.Duration < res.CopyDuration+res.VerifyDuration {
+		t.Errorf("unexpected durations %s, copy %s, verify %s", res.Duration, res.CopyDuration, res.VerifyDuration)
+	}
+
+	if e := finished["copy"]; e.Keys != res.KeysCopied || e.Bytes != res.BytesCopied || e.Error != "" {
+		t.Errorf("unexpected copy phase-finished event %+v", e)
+	}
+
+	if e := finished["verify"]; e.Keys != res.KeysVerified || e.Error != "" {
+		t.Errorf("unexpected verify phase-finished event %+v", e)
+	}
+
+	if batches == 0 || steps == 0 {
+		t.Errorf("expected batch-committed and step events, got %d and %d", batches, steps)
+	}
+
+	if !strings.Contains(logs.String(), "All tasks finished") {
+		t.Errorf("expected log messages in custom logger, got:\n%s", logs.String())
+	}
+
+	testutil.FinishTest(t, dir, s1, s2, 100, 100)
+}
+
+func TestConverterJSONEvents(t *testing.T) {
+	//Prepare repo
+	dir, _close, s1, s2 := testutil.PrepareTest(t, 100, 100)
+	defer _close(t)
+
+	testutil.PatchConfig(t, path.Join(dir, "config"), "../testfiles/badgerSpec")
+
+	out := new(bytes.Buffer)
+	w := convert.NewJSONWriter(out)
+
+	_, err := convert.NewConverter(convert.Options{
+		Logger:   w,
+		Progress: w.Event,
+	}).Convert(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	types := map[string]int{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var e map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("invalid JSON line '%s': %s", line, err)
+		}
+
+		typ, _ := e["type"].(string)
+		types[typ]++
+
+		if typ == "phase-finished" && e["phase"] == "copy" && e["keys"].(float64) < 101 {
+			t.Errorf("unexpected copy phase-finished event: %s", line)
+		}
+	}
+
+	for _, typ := range []string{"phase-started", "phase-finished", "batch-committed", "step", "log"} {
+		if types[typ] == 0 {
+			t.Errorf("no %s events in output:\n%s", typ, out.String())
+		}
+	}
+
+	testutil.FinishTest(t, dir, s1, s2, 100, 100)
+}
+
+func TestConverterReport(t *testing.T) {
+	//Prepare repo
+	dir, _close, s1, s2 := testutil.PrepareTest(t, 100, 100)
+	defer _close(t)
+
+	testutil.PatchConfig(t, path.Join(dir, "config"), "../testfiles/badgerSpec")
+
+	reportPath := path.Join(dir, repo.ReportFile)
+
+	_, err := convert.NewConverter(convert.Options{
+		KeepBackup: true,
+		Report:     reportPath,
+	}).Convert(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(reportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var report convert.Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+
+	if !report.Success || report.StrategyId == "" || report.FromSpec == nil || report.ToSpec == nil {
+		t.Errorf("unexpected report:\n%s", data)
+	}
+
+	if report.Verify.Mode != "keys" || report.Verify.KeysVerified != report.KeysCopied {
+		t.Errorf("unexpected verify report %+v", report.Verify)
+	}
+
+	mountKeys := 0
+	for _, m := range report.Mounts {
+		mountKeys += m.KeysCopied
+		if m.KeysVerified != m.KeysInNew || m.ExtraKeys != 0 {
+			t.Errorf("unexpected mount stats %+v", m)
+		}
+	}
+	if len(report.Mounts) == 0 || mountKeys != report.KeysCopied {
+		t.Errorf("unexpected mounts %+v", report.Mounts)
+	}
+
+	if len(report.Phases) != 2 || report.Phases[0].Phase != "copy" || report.Phases[1].Phase != "verify" {
+		t.Errorf("unexpected phases %+v", report.Phases)
+	}
+
+	//config backup, old datastore dir and spec backup
+	if len(report.Backups) != 3 {
+		t.Errorf("unexpected backups %v", report.Backups)
+	}
+	for _, p := range report.Backups {
+		if _, err := os.Stat(p); err != nil {
+			t.Error(err)
+		}
+	}
+
+	testutil.FinishTest(t, dir, s1, s2, 100, 100)
+}
+
+func TestConverterReportErrors(t *testing.T) {
+	//Prepare repo
+	dir, _close, s1, s2 := testutil.PrepareTest(t, 100, 100)
+	defer _close(t)
+
+	testutil.PatchConfig(t, path.Join(dir, "config"), "../testfiles/badgerSpec")
+
+	//repo used by daemon is left untouched
+	unlock, err := lock.Lock(dir, repo.LockFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = convert.NewConverter(convert.Options{
+		Report: path.Join(dir, repo.ReportFile),
+	}).Convert(context.Background(), dir)
+	if err == nil {
+		t.Fatal("expected locked repo error")
+	}
+	unlock.Close()
+
+	if _, err := os.Stat(path.Join(dir, repo.ReportFile)); !os.IsNotExist(err) {
+		t.Errorf("report written to locked repo: %v", err)
+	}
+
+	//failing report doesn't fail finished conversion
+	res, err := convert.NewConverter(convert.Options{
+		Report: path.Join(dir, "non/existent/report.json"),
+	}).Convert(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.ReportError == nil || !strings.Contains(res.ReportError.Error(), "writing report to") {
+		t.Errorf("unexpected report error: %v", res.ReportError)
+	}
+
+	testutil.FinishTest(t, dir, s1, s2, 100, 100)
+}
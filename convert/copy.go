@@ -1,12 +1,15 @@
 package convert
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
+	"sync"
 
 	"github.com/ipfs/ipfs-ds-convert/config"
 	"github.com/ipfs/ipfs-ds-convert/repo"
@@ -33,46 +36,130 @@ type Copy struct {
 	fromDs repo.Datastore
 	toDs   repo.Datastore
 
+	opts Options
+
+	//stats for Result
+	copied      int
+	copiedBytes int64
+	verified    int
+
+	//size of data to copy estimated by checkDiskSpace, used for ETA
+	estimatedBytes int64
+
+	//per mount stats, keyed by mountpoint
+	mountStats map[string]*MountStats
+
 	log     *revert.ActionLogger
 	logStep func(string, ...interface{})
 }
 
-func NewCopy(path string, fromSpec strategy.Spec, toSpec strategy.Spec, log *revert.ActionLogger, logStep func(string, ...interface{})) *Copy {
+func NewCopy(path string, fromSpec strategy.Spec, toSpec strategy.Spec, opts Options, log *revert.ActionLogger, logStep func(string, ...interface{})) *Copy {
 	return &Copy{
 		path:     path,
 		fromSpec: fromSpec,
 		toSpec:   toSpec,
+		opts:     opts,
 		log:      log,
 		logStep:  logStep,
 	}
 }
 
-func (c *Copy) Run() error {
+// Run copies keys into a new datastore and swaps it with the old one. When ctx
+// is cancelled, the batch being written is committed, datastores are closed
+// and ErrInterrupted is returned
+func (c *Copy) Run(ctx context.Context) error {
 	err := c.validateSpecs()
 	if err != nil {
 		return err
 	}
 
-	Log.Println("Checks OK")
+	err = c.checkDiskSpace("")
+	if err != nil {
+		return err
+	}
+
+	c.opts.logger().Printf("Checks OK")
 
 	err = c.openDatastores()
 	if err != nil {
 		return err
 	}
 
-	Log.Println("Copying keys, this can take a long time")
+	c.opts.logger().Printf("Copying keys, this can take a long time")
+
+	return c.copyAndSwap(ctx, nil)
+}
+
+// Resume continues copy phase of interrupted conversion from the checkpoint
+// recorded in convertlog
+func (c *Copy) Resume(ctx context.Context, cp *revert.Checkpoint) error {
+	err := c.validateSpecs()
+	if err != nil {
+		return err
+	}
+
+	err = c.checkDiskSpace(cp.Dir)
+	if err != nil {
+		return err
+	}
+
+	c.opts.logger().Printf("Checks OK")
 
-	err = CopyKeys(c.fromDs, c.toDs)
+	err = c.openResumedDatastores(cp.Dir)
 	if err != nil {
 		return err
 	}
 
+	c.opts.logger().Printf("Resuming copy after %d keys, this can take a long time", cp.Count)
+
+	return c.copyAndSwap(ctx, cp)
+}
+
+func (c *Copy) copyAndSwap(ctx context.Context, resume *revert.Checkpoint) error {
+	mounts, err := strategy.Mounts(c.toSpec)
+	if err != nil {
+		return err
+	}
+
+	put := func(key string, size int) {
+		s := c.mountStat(mountOf(mounts, key))
+		s.KeysCopied++
+		s.BytesCopied += int64(size)
+	}
+
+	ph := c.opts.startPhase("copy")
+	keys, bytes, err := copyKeys(ctx, c.fromDs, c.toDs, c.opts.Workers, resume, c.checkpoint, put, func(keys int, bytes int64, batch int) {
+		ph.progress(Event{
+			Type:       EventBatchCommitted,
+			Keys:       keys,
+			Bytes:      bytes,
+			TotalBytes: c.estimatedBytes,
+			Batch:      batch,
+		})
+	})
+	c.copied = keys
+	c.copiedBytes = bytes
+	ph.finish(keys, bytes, err)
+	if err != nil {
+		err2 := c.closeDatastores()
+		if err2 != nil {
+			c.opts.logger().Printf("%s", err2)
+		}
+
+		return err
+	}
+
 	err = c.closeDatastores()
 	if err != nil {
 		return err
 	}
 
-	Log.Println("All data copied, swapping repo")
+	//swapping is quick, but must not be left half done
+	if ctx.Err() != nil {
+		return ErrInterrupted
+	}
+
+	c.opts.logger().Printf("All data copied, swapping repo")
 
 	err = c.swapDatastores()
 	if err != nil {
@@ -82,14 +169,19 @@ func (c *Copy) Run() error {
 	return nil
 }
 
-func (c *Copy) Verify() error {
+// Verify checks the new datastore against the old one. When ctx is cancelled
+// verification stops and ErrInterrupted is returned
+func (c *Copy) Verify(ctx context.Context, mode VerifyMode) error {
 	err := c.openSwappedDatastores()
 	if err != nil {
 		return err
 	}
 
-	Log.Println("Verifying key integrity")
-	verified, err := c.verifyKeys()
+	c.opts.logger().Printf("Verifying key integrity (%s)", mode)
+	ph := c.opts.startPhase("verify")
+	verified, err := c.verifyKeys(ctx, mode, ph)
+	c.verified = verified
+	ph.finish(verified, 0, err)
 	if err != nil {
 		err2 := c.closeDatastores()
 		if err2 != nil {
@@ -98,7 +190,7 @@ func (c *Copy) Verify() error {
 
 		return err
 	}
-	Log.Printf("%d keys OK\n", verified)
+	c.opts.logger().Printf("%d keys OK", verified)
 
 	err = c.closeDatastores()
 	if err != nil {
@@ -152,82 +244,328 @@ func (c *Copy) openDatastores() (err error) {
 	return nil
 }
 
-func CopyKeys(fromDs repo.Datastore, toDs repo.Datastore) error {
+func (c *Copy) openResumedDatastores(dir string) (err error) {
+	if s, err := os.Stat(dir); err != nil || !s.IsDir() {
+		return fmt.Errorf("temp datastore %s from interrupted conversion is missing", dir)
+	}
+
+	c.fromDs, err = repo.OpenDatastore(c.path, c.fromSpec)
+	if err != nil {
+		return errors.Wrapf(err, "error opening datastore at %s", c.path)
+	}
+	c.logStep("open datastore at %s", c.path)
+
+	c.newDsDir = dir
+
+	c.toDs, err = repo.OpenDatastore(c.newDsDir, c.toSpec)
+	if err != nil {
+		return errors.Wrapf(err, "error opening new datastore at %s", c.newDsDir)
+	}
+	c.logStep("reopen new datastore at %s", c.newDsDir)
+
+	return nil
+}
+
+func (c *Copy) checkpoint(key string, n int) error {
+	return c.log.Checkpoint(c.newDsDir, key, n)
+}
+
+// copyChunk is a group of keys read from the old datastore by one worker
+type copyChunk struct {
+	seq  int
+	keys []string
+
+	//first check keys may already be present in the new datastore
+	check int
+
+	vals [][]byte
+	skip []bool
+	err  error
+}
+
+func (ch *copyChunk) read(fromDs repo.Datastore, toDs repo.Datastore) error {
+	ch.vals = make([][]byte, len(ch.keys))
+	ch.skip = make([]bool, len(ch.keys))
+
+	for i, key := range ch.keys {
+		if i < ch.check {
+			has, err := toDs.Has(ds.RawKey(key))
+			if err != nil {
+				return errors.Wrapf(err, "toDs.Has returned error")
+			}
+
+			if has {
+				ch.skip[i] = true
+				continue
+			}
+		}
+
+		val, err := fromDs.Get(ds.RawKey(key))
+		if err != nil {
+			return errors.Wrapf(err, "get from old datastore failed (dskey %s)", key)
+		}
+
+		ch.vals[i] = val
+	}
+
+	return nil
+}
+
+// CopyKeys copies all keys from fromDs to toDs. Values are read by workers
+// goroutines, batches are committed in query order. checkpoint is called with
+// the last key of every committed batch. When resume is set, keys up to the
+// checkpoint key are only copied if they are missing in toDs. When ctx is
+// cancelled, keys read so far are committed and ErrInterrupted is returned.
+// Progress is printed to stdout, see TextProgress
+func CopyKeys(ctx context.Context, fromDs repo.Datastore, toDs repo.Datastore, workers int, resume *revert.Checkpoint, checkpoint func(key string, n int) error) error {
+	opts := Options{Progress: TextProgress(os.Stdout)}
+
+	ph := opts.startPhase("copy")
+	keys, bytes, err := copyKeys(ctx, fromDs, toDs, workers, resume, checkpoint, nil, func(keys int, bytes int64, batch int) {
+		ph.progress(Event{Type: EventBatchCommitted, Keys: keys, Bytes: bytes, Batch: batch})
+	})
+	ph.finish(keys, bytes, err)
+
+	return err
+}
+
+// copyKeys is CopyKeys calling put, when not nil, for every key written and
+// progress after every committed batch. It returns number of keys in toDs,
+// including ones found already copied when resuming, and size of values written
+func copyKeys(ctx context.Context, fromDs repo.Datastore, toDs repo.Datastore, workers int, resume *revert.Checkpoint, checkpoint func(key string, n int) error, put func(key string, size int), progress func(keys int, bytes int64, batch int)) (int, int64, error) {
 	//flatfs only supports KeysOnly:true
 	//TODO: try to optimize this
 	res, err := fromDs.Query(dsq.Query{Prefix: "/", KeysOnly: true})
 	if err != nil {
-		return errors.Wrapf(err, "error opening query")
+		return 0, 0, errors.Wrapf(err, "error opening query")
 	}
 	defer res.Close()
 
+	if workers < 1 {
+		workers = 1
+	}
+
 	maxBatchEntries := 1024
 	maxBatchSize := 16 << 20
 
-	doneEntries := 0
-	curEntries := 0
-	curSize := 0
+	//keys are handed to workers in small chunks so that memory use stays
+	//bounded with many workers and large values
+	chunkEntries := 64
+
+	done := make(chan struct{})
+	chunks := make(chan *copyChunk)
+	results := make(chan *copyChunk)
+	inFlight := make(chan struct{}, 2*workers)
+
+	var wg sync.WaitGroup
+	defer func() {
+		close(done)
+		wg.Wait()
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(chunks)
+
+		//keys before checkpoint key were likely copied already
+		skipping := resume != nil && resume.Key != ""
+		cur := &copyChunk{}
+
+		send := func() bool {
+			select {
+			case inFlight <- struct{}{}:
+			case <-done:
+				return false
+			}
 
-	var curBatch ds.Batch
+			select {
+			case chunks <- cur:
+			case <-done:
+				return false
+			}
 
-	for {
-		entry, ok := res.NextSync()
-		if entry.Error != nil {
-			return errors.Wrapf(entry.Error, "entry.Error was not nil")
-		}
-		if !ok {
-			break
+			cur = &copyChunk{seq: cur.seq + 1}
+			return true
 		}
 
-		if curBatch == nil {
-			curBatch, err = toDs.Batch()
+		for {
+			entry, ok := res.NextSync()
 			if entry.Error != nil {
-				return errors.Wrapf(err, "error creating batch")
+				cur.err = errors.Wrapf(entry.Error, "entry.Error was not nil")
+				send()
+				return
+			}
+			if !ok {
+				break
+			}
+
+			cur.keys = append(cur.keys, entry.Key)
+
+			if skipping {
+				cur.check = len(cur.keys)
+				skipping = entry.Key != resume.Key
 			}
-			if curBatch == nil {
-				return errors.New("failed to create new batch")
+
+			if len(cur.keys) == chunkEntries && !send() {
+				return
 			}
 		}
 
-		val, err := fromDs.Get(ds.RawKey(entry.Key))
+		if len(cur.keys) > 0 {
+			send()
+		}
+	}()
+
+	var readers sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		readers.Add(1)
+		go func() {
+			defer wg.Done()
+			defer readers.Done()
+
+			for ch := range chunks {
+				if ch.err == nil {
+					ch.err = ch.read(fromDs, toDs)
+				}
+
+				select {
+				case results <- ch:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		readers.Wait()
+		close(results)
+	}()
+
+	doneEntries := 0
+	var doneBytes int64
+	curEntries := 0
+	curSize := 0
+
+	var curBatch ds.Batch
+	var lastKey string
+
+	commit := func() error {
+		err := curBatch.Commit()
 		if err != nil {
-			return errors.Wrapf(err, "get from old datastore failed (dskey %s)", entry.Key)
+			return errors.Wrapf(err, "batch commit failed")
 		}
 
-		curBatch.Put(ds.RawKey(entry.Key), val)
-		curEntries++
+		doneEntries += curEntries
+		doneBytes += int64(curSize)
+		progress(doneEntries, doneBytes, curEntries)
 
-		curSize += len(val)
+		curEntries = 0
+		curSize = 0
+		curBatch = nil
 
-		if curEntries == maxBatchEntries || curSize >= maxBatchSize {
-			err := curBatch.Commit()
-			if err != nil {
-				return errors.Wrapf(err, "batch commit failed")
+		return checkpoint(lastKey, doneEntries)
+	}
+
+	//chunks can be read out of order, keep them until all previous ones
+	//are committed
+	pending := map[int]*copyChunk{}
+	next := 0
+
+	for {
+		var r *copyChunk
+		var ok bool
+
+		select {
+		case r, ok = <-results:
+		case <-ctx.Done():
+			if curEntries > 0 {
+				err := commit()
+				if err != nil {
+					return doneEntries, doneBytes, err
+				}
+			}
+
+			return doneEntries, doneBytes, ErrInterrupted
+		}
+		if !ok {
+			break
+		}
+
+		pending[r.seq] = r
+
+		for {
+			ch, ok := pending[next]
+			if !ok {
+				break
 			}
+			delete(pending, next)
+			next++
+			<-inFlight
 
-			doneEntries += curEntries
-			fmt.Printf("\rcopied %d keys", doneEntries)
+			if ch.err != nil {
+				return doneEntries, doneBytes, ch.err
+			}
 
-			curEntries = 0
-			curSize = 0
-			curBatch = nil
+			for i, key := range ch.keys {
+				lastKey = key
+
+				if ch.skip[i] {
+					doneEntries++
+					continue
+				}
+
+				if curBatch == nil {
+					curBatch, err = toDs.Batch()
+					if err != nil {
+						return doneEntries, doneBytes, errors.Wrapf(err, "error creating batch")
+					}
+					if curBatch == nil {
+						return doneEntries, doneBytes, errors.New("failed to create new batch")
+					}
+				}
+
+				err := curBatch.Put(ds.RawKey(key), ch.vals[i])
+				if err != nil {
+					return doneEntries, doneBytes, errors.Wrapf(err, "batch put failed")
+				}
+				curEntries++
+
+				curSize += len(ch.vals[i])
+				if put != nil {
+					put(key, len(ch.vals[i]))
+				}
+
+				if curEntries == maxBatchEntries || curSize >= maxBatchSize {
+					err := commit()
+					if err != nil {
+						return doneEntries, doneBytes, err
+					}
+				}
+			}
 		}
 	}
 
-	fmt.Printf("\rcopied %d keys", doneEntries+curEntries)
-	fmt.Printf("\n")
+	if len(pending) != 0 {
+		return doneEntries, doneBytes, errors.New("copy workers exited with unprocessed keys")
+	}
 
 	if curEntries > 0 {
 		if curBatch == nil {
-			return errors.New("nil curBatch when there are unflushed entries")
+			return doneEntries, doneBytes, errors.New("nil curBatch when there are unflushed entries")
 		}
 
-		err := curBatch.Commit()
+		err := commit()
 		if err != nil {
-			return errors.Wrapf(err, "batch commit failed")
+			return doneEntries, doneBytes, err
 		}
 	}
-	return nil
+
+	return doneEntries, doneBytes, nil
 }
 
 func (c *Copy) swapDatastores() (err error) {
@@ -325,39 +663,6 @@ func (c *Copy) openSwappedDatastores() (err error) {
 	return nil
 }
 
-func (c *Copy) verifyKeys() (n int, err error) {
-	c.logStep("verify keys")
-
-	res, err := c.fromDs.Query(dsq.Query{Prefix: "/", KeysOnly: true})
-	if err != nil {
-		return n, errors.Wrapf(err, "error opening query")
-	}
-	defer res.Close()
-
-	for {
-		entry, ok := res.NextSync()
-		if entry.Error != nil {
-			return n, errors.Wrapf(entry.Error, "entry.Error was not nil")
-		}
-		if !ok {
-			break
-		}
-
-		has, err := c.toDs.Has(ds.RawKey(entry.Key))
-		if err != nil {
-			return n, errors.Wrapf(err, "toDs.Has returned error")
-		}
-
-		if !has {
-			return n, fmt.Errorf("key %s was not present in new datastore", entry.Key)
-		}
-
-		n++
-	}
-
-	return n, nil
-}
-
 func (c *Copy) closeDatastores() error {
 	err := c.fromDs.Close()
 	if err != nil {
@@ -373,6 +678,32 @@ func (c *Copy) closeDatastores() error {
 	return nil
 }
 
+// mountStat returns stats of mountpoint, creating them if needed
+func (c *Copy) mountStat(mountpoint string) *MountStats {
+	if c.mountStats == nil {
+		c.mountStats = map[string]*MountStats{}
+	}
+
+	s, ok := c.mountStats[mountpoint]
+	if !ok {
+		s = &MountStats{Mountpoint: mountpoint}
+		c.mountStats[mountpoint] = s
+	}
+	return s
+}
+
+func (c *Copy) sortedMountStats() []MountStats {
+	out := make([]MountStats, 0, len(c.mountStats))
+	for _, s := range c.mountStats {
+		out = append(out, *s)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Mountpoint < out[j].Mountpoint
+	})
+	return out
+}
+
 func (c *Copy) Clean() error {
 	err := c.log.Log(revert.ActionManual, "no backup data present for revert")
 	if err != nil {
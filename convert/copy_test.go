@@ -1,6 +1,8 @@
 package convert
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -8,7 +10,10 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/ipfs/ipfs-ds-convert/config"
 	"github.com/ipfs/ipfs-ds-convert/repo"
+	"github.com/ipfs/ipfs-ds-convert/revert"
+	"github.com/ipfs/ipfs-ds-convert/strategy"
 	"github.com/ipfs/ipfs-ds-convert/testutil"
 
 	ds "github.com/ipfs/go-datastore"
@@ -62,8 +67,8 @@ func TestInvalidSpecLeft(t *testing.T) {
 		t.Fatalf(err.Error())
 	}
 
-	c := NewCopy(d, InvalidSpec, ValidSpec, nil, func(string, ...interface{}) {})
-	err = c.Run()
+	c := NewCopy(d, InvalidSpec, ValidSpec, Options{}, nil, func(string, ...interface{}) {})
+	err = c.Run(context.Background())
 	if err != nil {
 		expect := fmt.Sprintf("error validating datastore spec in %s: invalid type entry in config", filepath.Join(d, "datastore_spec"))
 		if strings.Contains(err.Error(), expect) {
@@ -81,8 +86,8 @@ func TestInvalidSpecRight(t *testing.T) {
 		t.Fatalf(err.Error())
 	}
 
-	c := NewCopy(d, ValidSpec, InvalidSpec, nil, func(string, ...interface{}) {})
-	err = c.Run()
+	c := NewCopy(d, ValidSpec, InvalidSpec, Options{}, nil, func(string, ...interface{}) {})
+	err = c.Run(context.Background())
 	if err != nil {
 		if strings.Contains(err.Error(), fmt.Sprintf("error validating datastore spec in %s: invalid type entry in config", filepath.Join(d, "config"))) {
 			return
@@ -102,8 +107,8 @@ func TestOpenNonexist(t *testing.T) {
 	p := filepath.Join(d, "hopefully/nonexistent/repo")
 	expect := fmt.Sprintf("error opening datastore at %s: mkdir %s: ", p, filepath.Join(p, "blocks"))
 
-	c := NewCopy(p, ValidSpec, ValidSpec, nil, func(string, ...interface{}) {})
-	err = c.Run()
+	c := NewCopy(p, ValidSpec, ValidSpec, Options{}, nil, func(string, ...interface{}) {})
+	err = c.Run(context.Background())
 	if err != nil {
 		if strings.Contains(err.Error(), expect) {
 			return
@@ -121,8 +126,8 @@ func TestVerifyKeysFail(t *testing.T) {
 
 	testutil.PatchConfig(t, filepath.Join(dir, "config"), "../testfiles/singleSpec")
 
-	c := NewCopy(dir, DefaultSpec, SingleSpec, nil, func(string, ...interface{}) {})
-	if err := c.Run(); err != nil {
+	c := NewCopy(dir, DefaultSpec, SingleSpec, Options{}, nil, func(string, ...interface{}) {})
+	if err := c.Run(context.Background()); err != nil {
 		t.Fatal(err)
 	}
 
@@ -139,7 +144,230 @@ func TestVerifyKeysFail(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if err := c.Verify(); err.Error() != "key /blocks/NOTARANDOMKEY was not present in new datastore" {
+	if err := c.Verify(context.Background(), VerifyMode{}); err.Error() != "key /blocks/NOTARANDOMKEY was not present in new datastore" {
 		t.Fatal(err)
 	}
 }
+
+func TestResumeCopy(t *testing.T) {
+	dir, _close, s1, s2 := testutil.PrepareTest(t, 3000, 3000)
+	defer _close(t)
+
+	testutil.PatchConfig(t, filepath.Join(dir, "config"), "../testfiles/badgerSpec")
+
+	err := ConvertWithOptions(context.Background(), dir, Options{Resume: true})
+	if err == nil || !strings.Contains(err.Error(), "convertlog: ") {
+		t.Fatalf("expected missing convertlog error, got %v", err)
+	}
+
+	fromSpec := make(map[string]interface{})
+	if err := config.Load(filepath.Join(dir, repo.SpecsFile), &fromSpec); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.Validate(fromSpec, true); err != nil {
+		t.Fatal(err)
+	}
+
+	toSpec := make(map[string]interface{})
+	if err := config.Load("../testfiles/badgerSpec", &toSpec); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := strategy.NewStrategy(fromSpec, toSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	strat := s.Spec()
+	from, _ := strat.Sub("from")
+	to, _ := strat.Sub("to")
+
+	lg, err := revert.NewActionLogger(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//Simulate conversion interrupted in the middle of copying keys
+	c := NewCopy(dir, from, to, Options{Workers: 4}, lg, func(string, ...interface{}) {})
+	if err := c.validateSpecs(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.openDatastores(); err != nil {
+		t.Fatal(err)
+	}
+
+	interrupted := errors.New("interrupted")
+	err = CopyKeys(context.Background(), c.fromDs, c.toDs, 4, nil, func(key string, n int) error {
+		if err := c.checkpoint(key, n); err != nil {
+			return err
+		}
+
+		if n >= 2048 {
+			return interrupted
+		}
+		return nil
+	})
+	if err != interrupted {
+		t.Fatalf("expected interrupted copy, got %v", err)
+	}
+
+	if err := c.closeDatastores(); err != nil {
+		t.Fatal(err)
+	}
+	lg.Close()
+
+	cp, err := revert.LoadCheckpoint(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cp.Count != 2048 || cp.Dir != c.newDsDir {
+		t.Fatalf("unexpected checkpoint %v", cp)
+	}
+
+	err = ConvertWithOptions(context.Background(), dir, Options{Resume: true, Workers: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.FinishTest(t, dir, s1, s2, 3000, 3000)
+}
+
+func TestInterruptCopy(t *testing.T) {
+	dir, _close, s1, s2 := testutil.PrepareTest(t, 3000, 3000)
+	defer _close(t)
+
+	testutil.PatchConfig(t, filepath.Join(dir, "config"), "../testfiles/badgerSpec")
+
+	fromSpec := make(map[string]interface{})
+	if err := config.Load(filepath.Join(dir, repo.SpecsFile), &fromSpec); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.Validate(fromSpec, true); err != nil {
+		t.Fatal(err)
+	}
+
+	toSpec := make(map[string]interface{})
+	if err := config.Load("../testfiles/badgerSpec", &toSpec); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := strategy.NewStrategy(fromSpec, toSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	strat := s.Spec()
+	from, _ := strat.Sub("from")
+	to, _ := strat.Sub("to")
+
+	lg, err := revert.NewActionLogger(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewCopy(dir, from, to, Options{Workers: 4}, lg, func(string, ...interface{}) {})
+	if err := c.validateSpecs(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.openDatastores(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err = CopyKeys(ctx, c.fromDs, c.toDs, 4, nil, func(key string, n int) error {
+		if err := c.checkpoint(key, n); err != nil {
+			return err
+		}
+
+		if n >= 1024 {
+			cancel()
+		}
+		return nil
+	})
+	if err != ErrInterrupted {
+		t.Fatalf("expected interrupted copy, got %v", err)
+	}
+
+	if err := c.closeDatastores(); err != nil {
+		t.Fatal(err)
+	}
+	lg.Close()
+
+	cp, err := revert.LoadCheckpoint(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cp.Count < 1024 || cp.Count >= 6002 {
+		t.Fatalf("unexpected checkpoint %v", cp)
+	}
+
+	err = ConvertWithOptions(context.Background(), dir, Options{Resume: true, Workers: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.FinishTest(t, dir, s1, s2, 3000, 3000)
+}
+
+func TestInterruptedConvert(t *testing.T) {
+	dir, _close, s1, s2 := testutil.PrepareTest(t, 100, 100)
+	defer _close(t)
+
+	testutil.PatchConfig(t, filepath.Join(dir, "config"), "../testfiles/badgerSpec")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := ConvertWithOptions(ctx, dir, Options{})
+	if err != ErrInterrupted {
+		t.Fatalf("expected interrupted conversion, got %v", err)
+	}
+
+	err = revert.Revert(dir, false, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.PatchConfig(t, filepath.Join(dir, "config"), "../testfiles/defaultSpec")
+
+	testutil.FinishTest(t, dir, s1, s2, 100, 100)
+}
+
+func TestInterruptedVerify(t *testing.T) {
+	dir, _close, s1, s2 := testutil.PrepareTest(t, 100, 100)
+	defer _close(t)
+
+	testutil.PatchConfig(t, filepath.Join(dir, "config"), "../testfiles/badgerSpec")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	//interrupt after keys were copied, old datastore must be kept
+	err := ConvertWithOptions(ctx, dir, Options{Progress: func(e Event) {
+		if e.Type == EventPhaseStarted && e.Phase == "verify" {
+			cancel()
+		}
+	}})
+	if err != ErrInterrupted {
+		t.Fatalf("expected interrupted conversion, got %v", err)
+	}
+
+	if _, err := revert.LoadCheckpoint(dir); err == nil {
+		t.Fatal("expected conversion past copy phase not to be resumable")
+	}
+
+	err = revert.Revert(dir, false, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.PatchConfig(t, filepath.Join(dir, "config"), "../testfiles/defaultSpec")
+
+	testutil.FinishTest(t, dir, s1, s2, 100, 100)
+}
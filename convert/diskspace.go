@@ -0,0 +1,91 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	humanize "github.com/dustin/go-humanize"
+)
+
+var errNoDiskInfo = errors.New("free disk space information not available on this platform")
+
+// freeSpace returns number of bytes available to unprivileged users on the
+// filesystem containing path. It's a variable so tests can override it.
+var freeSpace = diskFree
+
+// checkDiskSpace estimates how much data will be copied based on size of old
+// datastore directories and refuses to start when there is not enough space
+// left in the repo. resumeDir is the temp datastore of resumed conversion,
+// data already copied there is not counted.
+func (c *Copy) checkDiskSpace(resumeDir string) error {
+	var needed int64
+	for _, dir := range c.oldPaths {
+		size, err := dirSize(filepath.Join(c.path, dir))
+		if err != nil {
+			return err
+		}
+
+		needed += size
+	}
+
+	if resumeDir != "" {
+		copied, err := dirSize(resumeDir)
+		if err != nil {
+			return err
+		}
+
+		needed -= copied
+		if needed < 0 {
+			needed = 0
+		}
+	}
+
+	c.estimatedBytes = needed
+
+	free, err := freeSpace(c.path)
+	if err == errNoDiskInfo || os.IsNotExist(err) {
+		c.opts.logger().Printf("Skipping disk space check: %s", err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	c.logStep("estimated %s to copy, %s available", humanize.IBytes(uint64(needed)), humanize.IBytes(free))
+
+	if uint64(needed) <= free {
+		return nil
+	}
+
+	if c.opts.IgnoreSpace {
+		c.opts.logger().Printf("Not enough disk space: about %s needed, %s available, continuing anyway", humanize.IBytes(uint64(needed)), humanize.IBytes(free))
+		return nil
+	}
+
+	return fmt.Errorf("not enough disk space in %s: conversion needs about %s, %s available. Free up some space or run with --ignore-space", c.path, humanize.IBytes(uint64(needed)), humanize.IBytes(free))
+}
+
+// dirSize returns total size of regular files in dir, missing directories
+// have zero size
+func dirSize(dir string) (int64, error) {
+	var size int64
+
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+
+	return size, err
+}
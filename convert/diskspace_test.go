@@ -0,0 +1,32 @@
+package convert
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/ipfs-ds-convert/testutil"
+)
+
+func TestNotEnoughSpace(t *testing.T) {
+	dir, _close, _, _ := testutil.PrepareTest(t, 100, 100)
+	defer _close(t)
+
+	defer func() {
+		freeSpace = diskFree
+	}()
+	freeSpace = func(string) (uint64, error) {
+		return 1024, nil
+	}
+
+	c := NewCopy(dir, DefaultSpec, SingleSpec, Options{}, nil, func(string, ...interface{}) {})
+	err := c.Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "not enough disk space in "+dir) {
+		t.Fatalf("expected not enough disk space error, got %v", err)
+	}
+
+	c = NewCopy(dir, DefaultSpec, SingleSpec, Options{IgnoreSpace: true}, nil, func(string, ...interface{}) {})
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,254 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+)
+
+// EventType identifies kind of Event
+type EventType string
+
+const (
+	// EventPhaseStarted is emitted when copy, verify, move, rename or reshard
+	// phase starts
+	EventPhaseStarted = EventType("phase-started")
+
+	// EventPhaseFinished is emitted when a phase ends, Error is set when it
+	// failed
+	EventPhaseFinished = EventType("phase-finished")
+
+	// EventBatchCommitted is emitted after every batch written to the new
+	// datastore while copying
+	EventBatchCommitted = EventType("batch-committed")
+
+	// EventProgress is emitted periodically while verifying keys or resharding
+	EventProgress = EventType("progress")
+
+	// EventStep is emitted for every conversion step, these are the steps
+	// listed in conversion error message
+	EventStep = EventType("step")
+
+	// EventLog carries log messages written through JSONWriter
+	EventLog = EventType("log")
+)
+
+// Event describes progress of conversion, it's passed to Options.Progress
+type Event struct {
+	Type EventType
+	Time time.Time
+
+	// Phase is "copy", "verify", "move", "rename" or "reshard"
+	Phase string
+
+	// Keys is the number of keys copied or verified, or number of files moved
+	// when resharding, so far
+	Keys int
+
+	// Total is the expected final value of Keys, 0 when not known
+	Total int
+
+	// Bytes is the size of values copied so far
+	Bytes int64
+
+	// TotalBytes is the estimated size of data to copy, 0 when not known
+	TotalBytes int64
+
+	// Batch is the number of keys in the committed batch
+	Batch int
+
+	// Rate is the number of keys processed per second since phase started
+	Rate float64
+
+	Elapsed time.Duration
+
+	// ETA is the estimated time left in the phase, 0 when not known
+	ETA time.Duration
+
+	// Message is the step or log message
+	Message string
+
+	// Error is set on EventPhaseFinished when the phase failed
+	Error string
+}
+
+// MarshalJSON encodes event with snake_case keys, durations in seconds and
+// zero fields omitted
+func (e Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type       EventType `json:"type"`
+		Time       time.Time `json:"time"`
+		Phase      string    `json:"phase,omitempty"`
+		Keys       int       `json:"keys,omitempty"`
+		Total      int       `json:"total,omitempty"`
+		Bytes      int64     `json:"bytes,omitempty"`
+		TotalBytes int64     `json:"total_bytes,omitempty"`
+		Batch      int       `json:"batch,omitempty"`
+		Rate       float64   `json:"rate,omitempty"`
+		Elapsed    float64   `json:"elapsed_seconds,omitempty"`
+		ETA        float64   `json:"eta_seconds,omitempty"`
+		Message    string    `json:"message,omitempty"`
+		Error      string    `json:"error,omitempty"`
+	}{
+		Type:       e.Type,
+		Time:       e.Time,
+		Phase:      e.Phase,
+		Keys:       e.Keys,
+		Total:      e.Total,
+		Bytes:      e.Bytes,
+		TotalBytes: e.TotalBytes,
+		Batch:      e.Batch,
+		Rate:       e.Rate,
+		Elapsed:    e.Elapsed.Seconds(),
+		ETA:        e.ETA.Seconds(),
+		Message:    e.Message,
+		Error:      e.Error,
+	})
+}
+
+// TextProgress returns Options.Progress callback rendering progress of
+// copy, verify and reshard phases as a single updated line on w
+func TextProgress(w io.Writer) func(Event) {
+	//set while progress line is not terminated
+	var open bool
+
+	return func(e Event) {
+		switch e.Type {
+		case EventBatchCommitted, EventProgress:
+		case EventPhaseFinished:
+			if open {
+				fmt.Fprintf(w, "\n")
+				open = false
+			}
+			return
+		default:
+			return
+		}
+
+		var line string
+		switch e.Phase {
+		case "copy":
+			line = fmt.Sprintf("copied %d keys, %s", e.Keys, humanize.IBytes(uint64(e.Bytes)))
+		case "verify":
+			line = fmt.Sprintf("verified %d keys", e.Keys)
+			if e.Total > 0 {
+				line = fmt.Sprintf("verified %d/%d keys", e.Keys, e.Total)
+			}
+		case "reshard":
+			line = fmt.Sprintf("moved %d files", e.Keys)
+		default:
+			return
+		}
+
+		if e.Rate > 0 {
+			line += fmt.Sprintf(", %.0f keys/s", e.Rate)
+		}
+		if e.ETA >= time.Second {
+			line += fmt.Sprintf(", ETA %s", e.ETA.Round(time.Second))
+		}
+
+		//trailing spaces clear leftovers of longer previous line
+		fmt.Fprintf(w, "\r%-70s", line)
+		open = true
+	}
+}
+
+// JSONWriter writes events and log messages to w as JSON lines. Use Event as
+// Options.Progress and the writer itself as Options.Logger
+type JSONWriter struct {
+	lk  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewJSONWriter(w io.Writer) *JSONWriter {
+	return &JSONWriter{
+		enc: json.NewEncoder(w),
+	}
+}
+
+// Event writes e as a single line
+func (j *JSONWriter) Event(e Event) {
+	j.lk.Lock()
+	defer j.lk.Unlock()
+
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	//errors writing to w can't be reported anywhere
+	_ = j.enc.Encode(e)
+}
+
+// Printf writes log message as EventLog
+func (j *JSONWriter) Printf(format string, v ...interface{}) {
+	j.Event(Event{Type: EventLog, Message: fmt.Sprintf(format, v...)})
+}
+
+// phase tracks a running phase and fills in rate and ETA of its events
+type phase struct {
+	opts  *Options
+	name  string
+	start time.Time
+}
+
+func (o *Options) event(e Event) {
+	if o.Progress == nil {
+		return
+	}
+
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	o.Progress(e)
+}
+
+func (o *Options) startPhase(name string) *phase {
+	p := &phase{
+		opts:  o,
+		name:  name,
+		start: time.Now(),
+	}
+
+	o.event(Event{Type: EventPhaseStarted, Phase: name, Time: p.start})
+	return p
+}
+
+// progress emits e with rate and ETA computed from phase start. ETA is based
+// on Total when set, on TotalBytes otherwise
+func (p *phase) progress(e Event) {
+	e.Phase = p.name
+	e.Elapsed = time.Since(p.start)
+
+	secs := e.Elapsed.Seconds()
+	if secs > 0 {
+		e.Rate = float64(e.Keys) / secs
+
+		switch {
+		case e.Total > e.Keys && e.Keys > 0:
+			e.ETA = time.Duration(float64(e.Total-e.Keys) / e.Rate * float64(time.Second))
+		case e.TotalBytes > e.Bytes && e.Bytes > 0:
+			e.ETA = time.Duration(float64(e.TotalBytes-e.Bytes) / (float64(e.Bytes) / secs) * float64(time.Second))
+		}
+	}
+
+	p.opts.event(e)
+}
+
+func (p *phase) finish(keys int, bytes int64, err error) {
+	e := Event{
+		Type:    EventPhaseFinished,
+		Phase:   p.name,
+		Keys:    keys,
+		Bytes:   bytes,
+		Elapsed: time.Since(p.start),
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	p.opts.event(e)
+}
@@ -0,0 +1,63 @@
+package convert
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ipfs/ipfs-ds-convert/revert"
+	"github.com/ipfs/ipfs-ds-convert/strategy"
+
+	errors "github.com/pkg/errors"
+)
+
+// Move renames datastore directories when only their path changes
+type Move struct {
+	path  string
+	moves []strategy.Move
+	opts  Options
+
+	log     *revert.ActionLogger
+	logStep func(string, ...interface{})
+}
+
+func NewMove(path string, moves []strategy.Move, opts Options, log *revert.ActionLogger, logStep func(string, ...interface{})) *Move {
+	return &Move{
+		path:    path,
+		moves:   moves,
+		opts:    opts,
+		log:     log,
+		logStep: logStep,
+	}
+}
+
+func (m *Move) Run() error {
+	for _, mv := range m.moves {
+		from := filepath.Join(m.path, mv.From)
+		to := filepath.Join(m.path, mv.To)
+
+		if _, err := os.Stat(to); !os.IsNotExist(err) {
+			return fmt.Errorf("can't move datastore to %s, destination already exists", to)
+		}
+
+		if _, err := os.Stat(from); os.IsNotExist(err) {
+			m.logStep("skip moving %s, directory doesn't exist", from)
+			continue
+		}
+
+		err := os.Rename(from, to)
+		if err != nil {
+			return errors.Wrapf(err, "error moving datastore dir %s to %s", from, to)
+		}
+
+		err = m.log.Log(revert.ActionMove, to, from)
+		if err != nil {
+			return err
+		}
+
+		m.logStep("move %s to %s", from, to)
+	}
+
+	m.opts.logger().Printf("Datastore directories moved")
+	return nil
+}
@@ -0,0 +1,236 @@
+package convert
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+
+	"github.com/ipfs/ipfs-ds-convert/config"
+	"github.com/ipfs/ipfs-ds-convert/repo"
+	"github.com/ipfs/ipfs-ds-convert/strategy"
+)
+
+// Plan computes the conversion strategy for the repo at repoPath and writes
+// a description of what 'convert' would do to out. Nothing in the repo is
+// modified and the repo lock is not taken.
+func Plan(repoPath string, out io.Writer) error {
+	return PlanWithOptions(repoPath, Options{}, out)
+}
+
+// PlanWithOptions is Plan for conversion run with opts
+func PlanWithOptions(repoPath string, opts Options, out io.Writer) error {
+	c := Conversion{
+		path: repoPath,
+		opts: opts,
+	}
+
+	err := c.checkRepoVersion()
+	if err != nil {
+		return err
+	}
+
+	err = c.loadSpecs()
+	if err != nil {
+		return err
+	}
+
+	s, err := strategy.NewStrategyWithOptions(c.fromSpec, c.toSpec, strategy.Options{RenameMounts: opts.RenameMounts})
+	if err != nil {
+		return err
+	}
+
+	strat := s.Spec()
+	conversionType, _ := strat.Type()
+
+	fmt.Fprintf(out, "Conversion strategy: %s\n", conversionType)
+
+	fromMounts, err := strategy.Mounts(c.fromSpec)
+	if err != nil {
+		return err
+	}
+
+	steps := []strategy.Spec{strat}
+	if conversionType == "multi" {
+		steps, _ = strat.Steps()
+	}
+
+	skipped := fromMounts
+	for _, step := range steps {
+		skipped, err = untouchedMounts(skipped, step)
+		if err != nil {
+			return err
+		}
+	}
+
+	printMounts(out, "Skipped mounts (data stays in place)", skipped)
+
+	for _, step := range steps {
+		err = printStep(out, step)
+		if err != nil {
+			return err
+		}
+	}
+
+	toDiskId, err := repo.DatastoreSpec(c.toSpec)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "New %s:\n  %s\n", repo.SpecsFile, toDiskId)
+	if opts.ToSpec != nil {
+		fmt.Fprintf(out, "Datastore.Spec in %s will be set to the new spec\n", repo.ConfigFile)
+	}
+	return nil
+}
+
+func printStep(out io.Writer, step strategy.Spec) error {
+	stepType, _ := step.Type()
+
+	switch stepType {
+	case "copy":
+		from, _ := step.Sub("from")
+		to, _ := step.Sub("to")
+
+		copyFrom, err := strategy.Mounts(from)
+		if err != nil {
+			return err
+		}
+
+		copyTo, err := strategy.Mounts(to)
+		if err != nil {
+			return err
+		}
+
+		printMounts(out, "Copied from", copyFrom)
+		printMounts(out, "Copied to", copyTo)
+
+		oldPaths, err := config.Validate(from, false)
+		if err != nil {
+			return err
+		}
+
+		newPaths, err := config.Validate(to, false)
+		if err != nil {
+			return err
+		}
+
+		sort.Strings(oldPaths)
+		sort.Strings(newPaths)
+
+		fmt.Fprintf(out, "Directories moved:\n")
+		for _, dir := range oldPaths {
+			fmt.Fprintf(out, "  %s -> %s\n", dir, filepath.Join("ds-convert-old*", dir))
+		}
+		for _, dir := range newPaths {
+			fmt.Fprintf(out, "  %s -> %s\n", filepath.Join("ds-convert*", dir), dir)
+		}
+	case "move":
+		moves, _ := step.Moves()
+
+		fmt.Fprintf(out, "Directories moved:\n")
+		for _, m := range moves {
+			fmt.Fprintf(out, "  %s -> %s\n", m.From, m.To)
+		}
+	case "rename":
+		renames, _ := step.Renames()
+
+		fmt.Fprintf(out, "Renamed mounts (data stays in place):\n")
+		for _, r := range renames {
+			fmt.Fprintf(out, "  %s -> %s at '%s'\n", r.From, r.To, r.Path)
+		}
+	case "reshard":
+		reshards, _ := step.Reshards()
+
+		fmt.Fprintf(out, "Resharded in place:\n")
+		for _, r := range reshards {
+			fmt.Fprintf(out, "  %s: %s -> %s\n", r.Path, r.From, r.To)
+		}
+	case "noop":
+	default:
+		return fmt.Errorf("unexpected strategy %s", stepType)
+	}
+
+	return nil
+}
+
+// untouchedMounts filters out mounts which are copied, moved, resharded or
+// renamed by step
+func untouchedMounts(mounts []strategy.MountInfo, step strategy.Spec) ([]strategy.MountInfo, error) {
+	var touched func(m strategy.MountInfo) bool
+
+	stepType, _ := step.Type()
+	switch stepType {
+	case "copy":
+		from, _ := step.Sub("from")
+
+		copied, err := strategy.Mounts(from)
+		if err != nil {
+			return nil, err
+		}
+
+		touched = func(m strategy.MountInfo) bool {
+			for _, c := range copied {
+				if m == c {
+					return true
+				}
+			}
+			return false
+		}
+	case "move":
+		moves, _ := step.Moves()
+
+		touched = func(m strategy.MountInfo) bool {
+			for _, mv := range moves {
+				if m.Path == mv.From {
+					return true
+				}
+			}
+			return false
+		}
+	case "reshard":
+		reshards, _ := step.Reshards()
+
+		touched = func(m strategy.MountInfo) bool {
+			for _, r := range reshards {
+				if m.Path == r.Path {
+					return true
+				}
+			}
+			return false
+		}
+	case "rename":
+		renames, _ := step.Renames()
+
+		touched = func(m strategy.MountInfo) bool {
+			for _, r := range renames {
+				if m.Prefix == r.From && m.Path == r.Path {
+					return true
+				}
+			}
+			return false
+		}
+	default:
+		return mounts, nil
+	}
+
+	var untouched []strategy.MountInfo
+	for _, m := range mounts {
+		if !touched(m) {
+			untouched = append(untouched, m)
+		}
+	}
+
+	return untouched, nil
+}
+
+func printMounts(out io.Writer, title string, mounts []strategy.MountInfo) {
+	fmt.Fprintf(out, "%s:\n", title)
+	if len(mounts) == 0 {
+		fmt.Fprintf(out, "  (none)\n")
+	}
+
+	for _, m := range mounts {
+		fmt.Fprintf(out, "  %s: %s at '%s'\n", m.Prefix, m.Type, m.Path)
+	}
+}
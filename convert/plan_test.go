@@ -0,0 +1,141 @@
+package convert_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/ipfs-ds-convert/config"
+	"github.com/ipfs/ipfs-ds-convert/convert"
+	"github.com/ipfs/ipfs-ds-convert/repo"
+	"github.com/ipfs/ipfs-ds-convert/revert"
+	"github.com/ipfs/ipfs-ds-convert/testutil"
+)
+
+func TestPlan(t *testing.T) {
+	//Prepare repo
+	dir, _close, s1, s2 := testutil.PrepareTest(t, 100, 100)
+	defer _close(t)
+
+	testutil.PatchConfig(t, path.Join(dir, "config"), "../testfiles/badgerSpec")
+
+	oldSpec, err := ioutil.ReadFile(path.Join(dir, repo.SpecsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := new(bytes.Buffer)
+	err = convert.Plan(dir, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		"Conversion strategy: copy",
+		"/blocks: flatfs at 'blocks'",
+		"/: levelds at 'datastore'",
+		"/: badgerds at 'badgerstore'",
+		"datastore -> ds-convert-old*/datastore",
+		"ds-convert*/badgerstore -> badgerstore",
+		`{"mounts":[{"mountpoint":"/blocks","path":"blocks","shardFunc":"/repo/flatfs/shard/v1/next-to-last/2","type":"flatfs"},{"mountpoint":"/","path":"badgerstore","type":"badgerds"}],"type":"mount"}`,
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected '%s' in plan, got:\n%s", expected, out.String())
+		}
+	}
+
+	if _, err := os.Stat(path.Join(dir, revert.ConvertLog)); !os.IsNotExist(err) {
+		t.Errorf("plan created %s", revert.ConvertLog)
+	}
+
+	newSpec, err := ioutil.ReadFile(path.Join(dir, repo.SpecsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(oldSpec, newSpec) {
+		t.Errorf("plan modified %s", repo.SpecsFile)
+	}
+
+	testutil.PatchConfig(t, path.Join(dir, "config"), "../testfiles/defaultSpec")
+
+	testutil.FinishTest(t, dir, s1, s2, 100, 100)
+}
+
+func TestPlanNoop(t *testing.T) {
+	//Prepare repo
+	dir, _close, _, _ := testutil.PrepareTest(t, 10, 10)
+	defer _close(t)
+
+	testutil.PatchConfig(t, path.Join(dir, "config"), "../testfiles/equalSpec")
+
+	out := new(bytes.Buffer)
+	err := convert.Plan(dir, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(out.String(), "Conversion strategy: noop") {
+		t.Errorf("expected noop strategy, got:\n%s", out.String())
+	}
+}
+
+func TestPlanRenameMounts(t *testing.T) {
+	spec := make(map[string]interface{})
+	err := config.Load("../testfiles/skipableSpec", &spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, _close := testutil.NewTestRepo(t, spec)
+	defer _close(t)
+
+	testutil.PatchConfig(t, path.Join(dir, "config"), "../testfiles/skipableDstSpec")
+
+	out := new(bytes.Buffer)
+	err = convert.PlanWithOptions(dir, convert.Options{RenameMounts: true}, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		"Conversion strategy: multi",
+		"Skipped mounts (data stays in place):\n  /a: badgerds at 'dsa'\n  /: badgerds at 'ds'\n",
+		"Renamed mounts (data stays in place):\n  /c -> /d at 'dsc'\n",
+		"/b: levelds at 'dsb'",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected '%s' in plan, got:\n%s", expected, out.String())
+		}
+	}
+}
+
+func TestPlanToSpec(t *testing.T) {
+	//Prepare repo
+	dir, _close, _, _ := testutil.PrepareTest(t, 10, 10)
+	defer _close(t)
+
+	spec := make(map[string]interface{})
+	if err := config.Load("../testfiles/badgerSpec", &spec); err != nil {
+		t.Fatal(err)
+	}
+
+	out := new(bytes.Buffer)
+	err := convert.PlanWithOptions(dir, convert.Options{ToSpec: spec}, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		"Conversion strategy: copy",
+		"/: badgerds at 'badgerstore'",
+		"Datastore.Spec in config will be set to the new spec",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected '%s' in plan, got:\n%s", expected, out.String())
+		}
+	}
+}
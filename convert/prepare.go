@@ -25,11 +25,7 @@ func (c *Conversion) checkRepoVersion() error {
 		return err
 	}
 
-	if version != repo.SupportedRepoVersion {
-		return fmt.Errorf("unsupported fsrepo version: %d", version)
-	}
-
-	return nil
+	return repo.CheckVersion(version)
 }
 
 func (c *Conversion) loadSpecs() error {
@@ -70,6 +66,11 @@ func (c *Conversion) loadSpecs() error {
 	if !ok {
 		return fmt.Errorf("no 'Datastore.Spec' or invalid type in %s", filepath.Join(c.path, repo.ConfigFile))
 	}
+	c.configSpec = dsSpec
+
+	if c.opts.ToSpec != nil {
+		dsSpec = c.opts.ToSpec
+	}
 
 	_, err = config.Validate(dsSpec, false)
 	if err != nil {
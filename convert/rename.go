@@ -0,0 +1,63 @@
+package convert
+
+import (
+	"fmt"
+
+	"github.com/ipfs/ipfs-ds-convert/repo"
+	"github.com/ipfs/ipfs-ds-convert/strategy"
+
+	dsq "github.com/ipfs/go-datastore/query"
+	errors "github.com/pkg/errors"
+)
+
+// Rename checks that mounts whose mountpoint changed can keep their data in
+// place. Nothing is changed on disk, keys of renamed mounts get their new
+// prefix once the new spec is saved
+type Rename struct {
+	path     string
+	fromSpec map[string]interface{}
+	renames  []strategy.Rename
+	opts     Options
+
+	logStep func(string, ...interface{})
+}
+
+func NewRename(path string, fromSpec map[string]interface{}, renames []strategy.Rename, opts Options, logStep func(string, ...interface{})) *Rename {
+	return &Rename{
+		path:     path,
+		fromSpec: fromSpec,
+		renames:  renames,
+		opts:     opts,
+		logStep:  logStep,
+	}
+}
+
+// Run fails if the old datastore has keys under any of the new prefixes, as
+// they would be hidden by the renamed mount
+func (r *Rename) Run() error {
+	fromDs, err := repo.OpenDatastore(r.path, r.fromSpec)
+	if err != nil {
+		return errors.Wrapf(err, "error opening datastore at %s", r.path)
+	}
+	defer fromDs.Close()
+
+	for _, rn := range r.renames {
+		res, err := fromDs.Query(dsq.Query{Prefix: rn.To, KeysOnly: true, Limit: 1})
+		if err != nil {
+			return errors.Wrapf(err, "error querying keys under %s", rn.To)
+		}
+
+		entries, err := res.Rest()
+		if err != nil {
+			return errors.Wrapf(err, "error querying keys under %s", rn.To)
+		}
+
+		if len(entries) != 0 {
+			return fmt.Errorf("can't rename mount %s to %s, old datastore has keys under %s", rn.From, rn.To, rn.To)
+		}
+
+		r.logStep("rename mount %s to %s, data stays in %s", rn.From, rn.To, rn.Path)
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package convert
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/ipfs/ipfs-ds-convert/config"
+	"github.com/ipfs/ipfs-ds-convert/repo"
+	"github.com/ipfs/ipfs-ds-convert/strategy"
+
+	errors "github.com/pkg/errors"
+)
+
+// Report is the machine-readable summary of a conversion written to
+// Options.Report
+type Report struct {
+	ToolVersion string    `json:"tool_version"`
+	Repo        string    `json:"repo"`
+	Started     time.Time `json:"started"`
+	Finished    time.Time `json:"finished"`
+
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+
+	FromSpec map[string]interface{} `json:"from_spec,omitempty"`
+	ToSpec   map[string]interface{} `json:"to_spec,omitempty"`
+
+	Strategy   strategy.Spec `json:"strategy,omitempty"`
+	StrategyId string        `json:"strategy_id,omitempty"`
+
+	KeysCopied  int          `json:"keys_copied"`
+	BytesCopied int64        `json:"bytes_copied"`
+	Verify      VerifyReport `json:"verify"`
+	Mounts      []MountStats `json:"mounts"`
+
+	Phases          []PhaseReport `json:"phases"`
+	DurationSeconds float64       `json:"duration_seconds"`
+
+	KeepBackup bool     `json:"keep_backup"`
+	Backups    []string `json:"backups"`
+}
+
+type VerifyReport struct {
+	Mode         string `json:"mode"`
+	KeysVerified int    `json:"keys_verified"`
+}
+
+type PhaseReport struct {
+	Phase           string  `json:"phase"`
+	Keys            int     `json:"keys"`
+	Bytes           int64   `json:"bytes"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	Error           string  `json:"error,omitempty"`
+}
+
+func (c *Conversion) report(start time.Time, err error) *Report {
+	r := &Report{
+		ToolVersion: repo.ToolVersion,
+		Repo:        c.path,
+		Started:     start,
+		Finished:    start.Add(c.result.Duration),
+		Success:     err == nil,
+
+		FromSpec:   c.fromSpec,
+		ToSpec:     c.toSpec,
+		Strategy:   c.result.Strategy,
+		StrategyId: c.result.StrategyId,
+
+		KeysCopied:  c.result.KeysCopied,
+		BytesCopied: c.result.BytesCopied,
+		Verify: VerifyReport{
+			Mode:         c.opts.Verify.String(),
+			KeysVerified: c.result.KeysVerified,
+		},
+		Mounts: c.result.Mounts,
+
+		Phases:          []PhaseReport{},
+		DurationSeconds: c.result.Duration.Seconds(),
+
+		KeepBackup: c.opts.KeepBackup,
+		Backups:    c.result.Backups,
+	}
+
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	if r.Mounts == nil {
+		r.Mounts = []MountStats{}
+	}
+	if r.Backups == nil {
+		r.Backups = []string{}
+	}
+
+	for _, p := range c.result.Phases {
+		r.Phases = append(r.Phases, PhaseReport{
+			Phase:           p.Phase,
+			Keys:            p.Keys,
+			Bytes:           p.Bytes,
+			DurationSeconds: p.Duration.Seconds(),
+			Error:           p.Error,
+		})
+	}
+
+	return r
+}
+
+// WriteReport writes r to path as indented JSON. The file is replaced
+// atomically, so an existing report is never left half written
+func WriteReport(path string, r *Report) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, "error encoding report")
+	}
+
+	//reports are kept as evidence, make them readable for other users
+	return config.WriteFileAtomic(path, append(data, '\n'), 0644)
+}
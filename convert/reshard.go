@@ -0,0 +1,69 @@
+package convert
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/ipfs/ipfs-ds-convert/repo"
+	"github.com/ipfs/ipfs-ds-convert/revert"
+	"github.com/ipfs/ipfs-ds-convert/strategy"
+
+	errors "github.com/pkg/errors"
+)
+
+// Reshard moves files of flatfs datastores between shard directories when
+// only the shard function changes
+type Reshard struct {
+	path     string
+	reshards []strategy.Reshard
+	opts     Options
+
+	log     *revert.ActionLogger
+	logStep func(string, ...interface{})
+}
+
+func NewReshard(path string, reshards []strategy.Reshard, opts Options, log *revert.ActionLogger, logStep func(string, ...interface{})) *Reshard {
+	return &Reshard{
+		path:     path,
+		reshards: reshards,
+		opts:     opts,
+		log:      log,
+		logStep:  logStep,
+	}
+}
+
+func (r *Reshard) Run() error {
+	for _, rs := range r.reshards {
+		dir := filepath.Join(r.path, rs.Path)
+
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			r.logStep("skip resharding %s, directory doesn't exist", dir)
+			continue
+		}
+
+		//logged before moving anything, reshard back is safe to run on
+		//partially resharded datastore
+		err := r.log.Log(revert.ActionReshard, dir, rs.From)
+		if err != nil {
+			return err
+		}
+
+		r.opts.logger().Printf("Resharding %s to %s, this can take a long time", dir, rs.To)
+
+		ph := r.opts.startPhase("reshard")
+		moved, err := repo.ReshardFlatfs(dir, rs.To, func(moved int) {
+			if moved%1000 == 0 {
+				ph.progress(Event{Type: EventProgress, Keys: moved})
+			}
+		})
+		ph.finish(moved, 0, err)
+		if err != nil {
+			return errors.Wrapf(err, "error resharding %s", dir)
+		}
+
+		r.logStep("reshard %s from %s to %s", dir, rs.From, rs.To)
+	}
+
+	r.opts.logger().Printf("Flatfs datastores resharded")
+	return nil
+}
@@ -0,0 +1,45 @@
+package convert
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ipfs/ipfs-ds-convert/config"
+	"github.com/ipfs/ipfs-ds-convert/repo"
+	"github.com/ipfs/ipfs-ds-convert/revert"
+
+	"github.com/pkg/errors"
+)
+
+// SetSpec validates spec and writes it into Datastore.Spec in config of the
+// repo at repoPath, see config.SetSpec. It refuses to run while convertlog
+// exists, as changing the target spec in the middle of conversion would break
+// resume and revert. Path of the config backup is returned
+func SetSpec(repoPath string, spec map[string]interface{}) (string, error) {
+	return SetSpecWithOptions(repoPath, spec, Options{})
+}
+
+// SetSpecWithOptions is SetSpec honouring opts.BreakLock and opts.Logger
+func SetSpecWithOptions(repoPath string, spec map[string]interface{}, opts Options) (string, error) {
+	_, err := config.Validate(spec, false)
+	if err != nil {
+		return "", errors.Wrapf(err, "validating new spec")
+	}
+
+	unlock, err := lockRepo(repoPath, opts.BreakLock, opts.logger())
+	if err != nil {
+		return "", err
+	}
+	defer unlock.Close()
+
+	logPath := filepath.Join(repoPath, revert.ConvertLog)
+	if _, err := os.Stat(logPath); !os.IsNotExist(err) {
+		if err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("%s exists, finish, revert or clean up the last conversion before changing the spec", logPath)
+	}
+
+	return config.SetSpec(filepath.Join(repoPath, repo.ConfigFile), spec)
+}
@@ -0,0 +1,101 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/ipfs-ds-convert/config"
+	"github.com/ipfs/ipfs-ds-convert/repo"
+	"github.com/ipfs/ipfs-ds-convert/revert"
+	"github.com/ipfs/ipfs-ds-convert/testutil"
+)
+
+func TestSetSpec(t *testing.T) {
+	dir, _close, _, _ := testutil.PrepareTest(t, 10, 10)
+	defer _close(t)
+
+	configPath := filepath.Join(dir, repo.ConfigFile)
+	oldConfig, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = SetSpec(dir, InvalidSpec)
+	if err == nil || !strings.Contains(err.Error(), "validating new spec") {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+
+	spec, err := config.Profile("badgerds")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backup, err := SetSpec(dir, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backupConfig, err := ioutil.ReadFile(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(backupConfig) != string(oldConfig) {
+		t.Errorf("backup differs from old config")
+	}
+
+	repoConfig := make(map[string]interface{})
+	if err := config.Load(configPath, &repoConfig); err != nil {
+		t.Fatal(err)
+	}
+
+	expected, _ := config.Profile("badgerds")
+	if !reflect.DeepEqual(repoConfig["Datastore"].(map[string]interface{})["Spec"], expected) {
+		t.Errorf("unexpected spec in config: %v", repoConfig["Datastore"])
+	}
+
+	lg, err := revert.NewActionLogger(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lg.Close()
+
+	_, err = SetSpec(dir, spec)
+	if err == nil || !strings.Contains(err.Error(), "convertlog exists") {
+		t.Fatalf("expected convertlog error, got %v", err)
+	}
+}
+
+func TestSetSpecDaemonRunning(t *testing.T) {
+	dir, _close, _, _ := testutil.PrepareTest(t, 10, 10)
+	defer _close(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	port := l.Addr().(*net.TCPAddr).Port
+	err = ioutil.WriteFile(filepath.Join(dir, repo.ApiFile), []byte(fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", port)), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spec, err := config.Profile("badgerds")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = SetSpec(dir, spec)
+	var daemon *repo.DaemonRunningError
+	if !errors.As(err, &daemon) {
+		t.Fatalf("expected daemon running error, got %v", err)
+	}
+}
@@ -2,13 +2,18 @@ package convert
 
 import (
 	"fmt"
+	"io"
 	"strings"
 
+	"github.com/ipfs/ipfs-ds-convert/repo"
+
 	errors "github.com/pkg/errors"
 )
 
 func (c *Conversion) addStep(format string, args ...interface{}) {
-	c.steps = append(c.steps, fmt.Sprintf(format, args...))
+	step := fmt.Sprintf(format, args...)
+	c.steps = append(c.steps, step)
+	c.opts.event(Event{Type: EventStep, Message: step})
 }
 
 func (c *Conversion) wrapErr(err error) error {
@@ -16,3 +21,19 @@ func (c *Conversion) wrapErr(err error) error {
 
 	return errors.Wrapf(err, "CONVERSION ERROR\n----------\nConversion steps done so far:\n%s\n----------\n", s)
 }
+
+// lockRepo takes repo lock with repo.Lock. With breakLock stale lock is
+// removed first
+func lockRepo(repoPath string, breakLock bool, log Logger) (io.Closer, error) {
+	unlock, err := repo.Lock(repoPath)
+	var stale *repo.StaleLockError
+	if breakLock && errors.As(err, &stale) {
+		log.Printf("Removing stale lock %s: %s", stale.Path, stale.Reason)
+		err = repo.BreakLock(repoPath)
+		if err == nil {
+			unlock, err = repo.Lock(repoPath)
+		}
+	}
+
+	return unlock, err
+}
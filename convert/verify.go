@@ -0,0 +1,250 @@
+package convert
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ipfs/ipfs-ds-convert/strategy"
+
+	ds "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	errors "github.com/pkg/errors"
+)
+
+// VerifyMode selects how copied data is checked after conversion. Presence of
+// every key is always checked.
+type VerifyMode struct {
+	// Values enables comparing values in old and new datastore
+	Values bool
+
+	// Sample limits value comparison to a random sample of keys, 0 compares
+	// values of all keys
+	Sample int
+}
+
+// ParseVerifyMode parses --verify flag values: 'keys' (default), 'full' or
+// 'sample:N'
+func ParseVerifyMode(s string) (VerifyMode, error) {
+	switch {
+	case s == "" || s == "keys":
+		return VerifyMode{}, nil
+	case s == "full":
+		return VerifyMode{Values: true}, nil
+	case strings.HasPrefix(s, "sample:"):
+		n, err := strconv.Atoi(strings.TrimPrefix(s, "sample:"))
+		if err != nil || n < 1 {
+			return VerifyMode{}, fmt.Errorf("invalid verify sample size in '%s'", s)
+		}
+
+		return VerifyMode{Values: true, Sample: n}, nil
+	default:
+		return VerifyMode{}, fmt.Errorf("unknown verify mode '%s'", s)
+	}
+}
+
+func (m VerifyMode) String() string {
+	switch {
+	case !m.Values:
+		return "keys"
+	case m.Sample > 0:
+		return fmt.Sprintf("sample:%d", m.Sample)
+	default:
+		return "full"
+	}
+}
+
+// verifyKeys checks keys of the old datastore are present in the new one,
+// progress is reported to ph every 1000 keys
+func (c *Copy) verifyKeys(ctx context.Context, mode VerifyMode, ph *phase) (n int, err error) {
+	c.logStep("verify keys (%s)", mode)
+
+	mounts, err := strategy.Mounts(c.toSpec)
+	if err != nil {
+		return n, err
+	}
+
+	res, err := c.fromDs.Query(dsq.Query{Prefix: "/", KeysOnly: true})
+	if err != nil {
+		return n, errors.Wrapf(err, "error opening query")
+	}
+	defer res.Close()
+
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	var sample []string
+	mismatched := 0
+	perMount := map[string]int{}
+
+	for {
+		if ctx.Err() != nil {
+			return n, ErrInterrupted
+		}
+
+		entry, ok := res.NextSync()
+		if entry.Error != nil {
+			return n, errors.Wrapf(entry.Error, "entry.Error was not nil")
+		}
+		if !ok {
+			break
+		}
+
+		has, err := c.toDs.Has(ds.RawKey(entry.Key))
+		if err != nil {
+			return n, errors.Wrapf(err, "toDs.Has returned error")
+		}
+
+		if !has {
+			return n, fmt.Errorf("key %s was not present in new datastore", entry.Key)
+		}
+
+		n++
+		perMount[mountOf(mounts, entry.Key)]++
+
+		if n%1000 == 0 {
+			ph.progress(Event{Type: EventProgress, Keys: n, Total: c.copied})
+		}
+
+		switch {
+		case !mode.Values:
+		case mode.Sample == 0:
+			ok, err := c.compareValues(entry.Key, mounts)
+			if err != nil {
+				return n, err
+			}
+			if !ok {
+				mismatched++
+			}
+		case len(sample) < mode.Sample:
+			sample = append(sample, entry.Key)
+		default:
+			//reservoir sampling, every key has equal chance of being checked
+			if i := rnd.Intn(n); i < mode.Sample {
+				sample[i] = entry.Key
+			}
+		}
+	}
+
+	for _, key := range sample {
+		if ctx.Err() != nil {
+			return n, ErrInterrupted
+		}
+
+		ok, err := c.compareValues(key, mounts)
+		if err != nil {
+			return n, err
+		}
+		if !ok {
+			mismatched++
+		}
+	}
+
+	if mismatched > 0 {
+		return n, fmt.Errorf("%d keys have different values in new datastore", mismatched)
+	}
+
+	extra, err := c.verifyReverse(ctx, mounts, perMount)
+	if err != nil {
+		return n, err
+	}
+
+	if extra > 0 {
+		return n, fmt.Errorf("%d keys in new datastore were not present in old datastore", extra)
+	}
+
+	return n, nil
+}
+
+// verifyReverse walks the new datastore and counts keys which are missing in
+// the old one, so that stale data in reused directories or unexpected mount
+// routing is detected. Per mount key counts are logged.
+func (c *Copy) verifyReverse(ctx context.Context, mounts []strategy.MountInfo, copied map[string]int) (extra int, err error) {
+	c.logStep("verify new datastore has no extra keys")
+
+	res, err := c.toDs.Query(dsq.Query{Prefix: "/", KeysOnly: true})
+	if err != nil {
+		return 0, errors.Wrapf(err, "error opening query")
+	}
+	defer res.Close()
+
+	total := map[string]int{}
+	extraPerMount := map[string]int{}
+
+	for {
+		if ctx.Err() != nil {
+			return extra, ErrInterrupted
+		}
+
+		entry, ok := res.NextSync()
+		if entry.Error != nil {
+			return extra, errors.Wrapf(entry.Error, "entry.Error was not nil")
+		}
+		if !ok {
+			break
+		}
+
+		mount := mountOf(mounts, entry.Key)
+		total[mount]++
+
+		has, err := c.fromDs.Has(ds.RawKey(entry.Key))
+		if err != nil {
+			return extra, errors.Wrapf(err, "fromDs.Has returned error")
+		}
+
+		if !has {
+			c.opts.logger().Printf("extra key: %s in mount %s was not present in old datastore", entry.Key, mount)
+			extraPerMount[mount]++
+			extra++
+		}
+	}
+
+	for _, m := range mounts {
+		c.opts.logger().Printf("mount %s: %d keys copied, %d keys in new datastore, %d extra", m.Prefix, copied[m.Prefix], total[m.Prefix], extraPerMount[m.Prefix])
+
+		s := c.mountStat(m.Prefix)
+		s.KeysVerified = copied[m.Prefix]
+		s.KeysInNew = total[m.Prefix]
+		s.ExtraKeys = extraPerMount[m.Prefix]
+	}
+
+	return extra, nil
+}
+
+// compareValues checks if value of key is the same in both datastores,
+// mismatches are logged with mountpoint the key belongs to
+func (c *Copy) compareValues(key string, mounts []strategy.MountInfo) (bool, error) {
+	oldVal, err := c.fromDs.Get(ds.RawKey(key))
+	if err != nil {
+		return false, errors.Wrapf(err, "get from old datastore failed (dskey %s)", key)
+	}
+
+	newVal, err := c.toDs.Get(ds.RawKey(key))
+	if err != nil {
+		return false, errors.Wrapf(err, "get from new datastore failed (dskey %s)", key)
+	}
+
+	if !bytes.Equal(oldVal, newVal) {
+		c.opts.logger().Printf("value mismatch: key %s in mount %s, old %d bytes, new %d bytes", key, mountOf(mounts, key), len(oldVal), len(newVal))
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// mountOf returns the mountpoint a key is routed to
+func mountOf(mounts []strategy.MountInfo, key string) string {
+	k := ds.RawKey(key)
+	best := ""
+
+	for _, m := range mounts {
+		prefix := ds.NewKey(m.Prefix)
+		if (prefix.Equal(k) || prefix.IsAncestorOf(k)) && len(m.Prefix) > len(best) {
+			best = m.Prefix
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,134 @@
+package convert
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/ipfs-ds-convert/repo"
+	"github.com/ipfs/ipfs-ds-convert/strategy"
+	"github.com/ipfs/ipfs-ds-convert/testutil"
+
+	ds "github.com/ipfs/go-datastore"
+)
+
+func TestParseVerifyMode(t *testing.T) {
+	cases := map[string]VerifyMode{
+		"":           {},
+		"keys":       {},
+		"full":       {Values: true},
+		"sample:100": {Values: true, Sample: 100},
+	}
+
+	for s, expected := range cases {
+		mode, err := ParseVerifyMode(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if mode != expected {
+			t.Errorf("unexpected mode for '%s': %v", s, mode)
+		}
+	}
+
+	for _, s := range []string{"sample:", "sample:0", "sample:a", "nope"} {
+		if _, err := ParseVerifyMode(s); err == nil {
+			t.Errorf("expected error for '%s'", s)
+		}
+	}
+}
+
+func TestVerifyValuesFail(t *testing.T) {
+	dir, _close, _, _ := testutil.PrepareTest(t, 100, 100)
+	defer _close(t)
+
+	testutil.PatchConfig(t, filepath.Join(dir, "config"), "../testfiles/singleSpec")
+
+	c := NewCopy(dir, DefaultSpec, SingleSpec, Options{}, nil, func(string, ...interface{}) {})
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := repo.OpenDatastore(dir, SingleSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Put(ds.NewKey("/blocks/NOTARANDOMKEY"), []byte("dat")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Verify(context.Background(), VerifyMode{}); err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.Verify(context.Background(), VerifyMode{Values: true})
+	if err == nil || !strings.Contains(err.Error(), "1 keys have different values in new datastore") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = c.Verify(context.Background(), VerifyMode{Values: true, Sample: 1000})
+	if err == nil || !strings.Contains(err.Error(), "1 keys have different values in new datastore") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestVerifyExtraKeys(t *testing.T) {
+	dir, _close, _, _ := testutil.PrepareTest(t, 100, 100)
+	defer _close(t)
+
+	testutil.PatchConfig(t, filepath.Join(dir, "config"), "../testfiles/singleSpec")
+
+	c := NewCopy(dir, DefaultSpec, SingleSpec, Options{}, nil, func(string, ...interface{}) {})
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Verify(context.Background(), VerifyMode{}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := repo.OpenDatastore(dir, SingleSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Put(ds.NewKey("/blocks/NOTINOLDDATASTORE"), []byte("dat")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Put(ds.NewKey("/other/NOTINOLDDATASTORE"), []byte("dat")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.Verify(context.Background(), VerifyMode{})
+	if err == nil || !strings.Contains(err.Error(), "2 keys in new datastore were not present in old datastore") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestMountOf(t *testing.T) {
+	mounts := []strategy.MountInfo{{Prefix: "/blocks"}, {Prefix: "/"}, {Prefix: "/a/b"}}
+
+	cases := map[string]string{
+		"/blocks/abc": "/blocks",
+		"/blocksabc":  "/",
+		"/a/b":        "/a/b",
+		"/a/c":        "/",
+	}
+
+	for key, expected := range cases {
+		if m := mountOf(mounts, key); m != expected {
+			t.Errorf("expected %s to be in mount %s, got %s", key, expected, m)
+		}
+	}
+}
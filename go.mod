@@ -3,8 +3,11 @@ module github.com/ipfs/ipfs-ds-convert
 go 1.16
 
 require (
+	github.com/dgraph-io/badger/v2 v2.2007.3
+	github.com/dustin/go-humanize v1.0.0
 	github.com/ipfs/go-datastore v0.4.6
 	github.com/ipfs/go-ds-badger v0.2.7
+	github.com/ipfs/go-ds-badger2 v0.1.1
 	github.com/ipfs/go-ds-flatfs v0.4.5
 	github.com/ipfs/go-ds-leveldb v0.4.2
 	github.com/ipfs/go-ds-measure v0.1.0
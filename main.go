@@ -1,9 +1,19 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 
+	"github.com/ipfs/ipfs-ds-convert/config"
 	"github.com/ipfs/ipfs-ds-convert/convert"
 	"github.com/ipfs/ipfs-ds-convert/repo"
 	"github.com/ipfs/ipfs-ds-convert/revert"
@@ -19,6 +29,12 @@ const (
 	EnvDir            = "IPFS_PATH"
 )
 
+// Exit codes for failures which scripts may want to handle
+const (
+	ExitDaemonRunning = 2
+	ExitStaleLock     = 3
+)
+
 func main() {
 	run(os.Args)
 }
@@ -41,8 +57,11 @@ func run(args []string) {
 
 	app.Commands = []cli.Command{
 		ConvertCommand,
+		PlanCommand,
 		RevertCommand,
 		CleanupCommand,
+		StatusCommand,
+		ConfigCommand,
 	}
 
 	if err := app.Run(args); err != nil {
@@ -50,6 +69,70 @@ func run(args []string) {
 	}
 }
 
+// fatal logs err and exits with code telling apart running daemon and stale
+// lock from other errors
+func fatal(err error) {
+	var daemon *repo.DaemonRunningError
+	var stale *repo.StaleLockError
+
+	convert.Log.Print(err)
+	switch {
+	case errors.As(err, &daemon):
+		os.Exit(ExitDaemonRunning)
+	case errors.As(err, &stale):
+		os.Exit(ExitStaleLock)
+	default:
+		os.Exit(1)
+	}
+}
+
+var breakLockFlag = cli.BoolFlag{
+	Name:  "break-lock",
+	Usage: "remove repo lock left by a program which is no longer running",
+}
+
+var renameMountsFlag = cli.BoolFlag{
+	Name:  "rename-mounts",
+	Usage: "keep data of mounts whose mountpoint changed in place, their keys get the new prefix",
+}
+
+var toProfileFlag = cli.StringFlag{
+	Name:  "to-profile",
+	Usage: "convert to named datastore profile instead of Datastore.Spec in config: " + strings.Join(config.ProfileNames(), ", "),
+}
+
+var toSpecFlag = cli.StringFlag{
+	Name:  "to-spec",
+	Usage: "convert to datastore spec read from JSON file instead of Datastore.Spec in config",
+}
+
+// planOptions returns options shared by convert and plan
+func planOptions(c *cli.Context) (convert.Options, error) {
+	opts := convert.Options{
+		RenameMounts: c.Bool("rename-mounts"),
+	}
+
+	switch {
+	case c.IsSet("to-profile") && c.IsSet("to-spec"):
+		return opts, errors.New("--to-profile and --to-spec can't be used together")
+	case c.IsSet("to-profile"):
+		spec, err := config.Profile(c.String("to-profile"))
+		if err != nil {
+			return opts, err
+		}
+		opts.ToSpec = spec
+	case c.IsSet("to-spec"):
+		spec := make(map[string]interface{})
+		err := config.Load(c.String("to-spec"), &spec)
+		if err != nil {
+			return opts, err
+		}
+		opts.ToSpec = spec
+	}
+
+	return opts, nil
+}
+
 var ConvertCommand = cli.Command{
 	Name:  "convert",
 	Usage: "convert datastore ",
@@ -57,11 +140,44 @@ var ConvertCommand = cli.Command{
 ipfs configuration and repo specs.
 
 Note that depending on configuration you are converting to up to double the
-disk space may be required.
+disk space may be required. Conversion won't start when there is not enough
+free space for copied data, unless --ignore-space is passed.
 
 If you have any doubts about your configuration, run the tool conversion with
 --keep option enabled
 
+If copying keys gets interrupted, it can be continued from the last checkpoint
+with --resume instead of running 'revert' and starting over. On SIGINT or
+SIGTERM the current batch is written, datastores are closed and the command to
+run next is printed
+
+Mounts which only change their directory are moved instead of copied, flatfs
+datastores which only change their shard function are resharded in place. With
+--rename-mounts, mounts which keep their directory but change mountpoint are
+left in place too, which changes the prefix of keys stored in them
+
+With --to-profile or --to-spec, the conversion targets a built-in datastore
+profile or a spec read from JSON file instead of Datastore.Spec in config. The
+spec is written into config together with datastore_spec when conversion
+finishes, the change is recorded in convertlog and 'revert' restores the old
+config
+
+When conversion ends, successfully or not, a JSON report with specs, strategy,
+per mount key counts, verification results, phase timings and backup paths is
+written to 'convert_report.json' in the repo, or to the path given by --report.
+No report is written when the repo couldn't be locked. Failing to write the
+report is only a warning
+
+With --log-format=json, log messages and progress events (phase started or
+finished, batch committed, verification progress, conversion steps) are
+written to stdout as JSON lines
+
+Before taking the repo lock, the API address from the 'api' file is checked
+for a running daemon. The command exits with code 2 when ipfs daemon or another
+program is using the repo, and with code 3 when the repo lock was left by a
+program which is no longer running. Such a stale lock can be removed with
+--break-lock
+
 IPFS_PATH environmental variable is respected
 	`,
 	Flags: []cli.Flag{
@@ -69,6 +185,115 @@ IPFS_PATH environmental variable is respected
 			Name:  "keep",
 			Usage: "don't remove backup files after successful conversion",
 		},
+		cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "only print what would be done, same as 'plan'",
+		},
+		cli.BoolFlag{
+			Name:  "resume",
+			Usage: "continue interrupted conversion from the last checkpoint",
+		},
+		cli.IntFlag{
+			Name:  "workers",
+			Usage: "number of parallel readers used when copying keys",
+			Value: 1,
+		},
+		cli.StringFlag{
+			Name:  "verify",
+			Usage: "verification mode: 'keys' checks presence of keys, 'full' compares all values, 'sample:N' compares values of N random keys",
+			Value: "keys",
+		},
+		cli.BoolFlag{
+			Name:  "ignore-space",
+			Usage: "don't check if there is enough free disk space before copying",
+		},
+		renameMountsFlag,
+		toProfileFlag,
+		toSpecFlag,
+		breakLockFlag,
+		cli.StringFlag{
+			Name:  "report",
+			Usage: "path of the JSON conversion report, defaults to " + repo.ReportFile + " in the repo",
+		},
+		cli.StringFlag{
+			Name:  "log-format",
+			Usage: "output format of log messages and progress: 'text' or 'json' (one event per line on stdout)",
+			Value: "text",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		baseDir, err := getBaseDir()
+		if err != nil {
+			convert.Log.Fatal(err)
+		}
+
+		opts, err := planOptions(c)
+		if err != nil {
+			convert.Log.Fatal(err)
+		}
+
+		if c.Bool("dry-run") {
+			err = convert.PlanWithOptions(baseDir, opts, os.Stdout)
+			if err != nil {
+				convert.Log.Fatal(err)
+			}
+			return err
+		}
+
+		verify, err := convert.ParseVerifyMode(c.String("verify"))
+		if err != nil {
+			convert.Log.Fatal(err)
+		}
+
+		opts.KeepBackup = c.Bool("keep")
+		opts.Resume = c.Bool("resume")
+		opts.Workers = c.Int("workers")
+		opts.Verify = verify
+		opts.IgnoreSpace = c.Bool("ignore-space")
+		opts.BreakLock = c.Bool("break-lock")
+		opts.Report = c.String("report")
+
+		if opts.Report == "" {
+			opts.Report = filepath.Join(baseDir, repo.ReportFile)
+		}
+
+		switch c.String("log-format") {
+		case "text":
+		case "json":
+			w := convert.NewJSONWriter(os.Stdout)
+			opts.Logger = w
+			opts.Progress = w.Event
+		default:
+			convert.Log.Fatalf("unknown log format '%s'", c.String("log-format"))
+		}
+
+		ctx, stop := interruptContext()
+		defer stop()
+
+		err = convert.ConvertWithOptions(ctx, baseDir, opts)
+		if errors.Is(err, convert.ErrInterrupted) {
+			convert.Log.Printf("Conversion interrupted, to continue run:\n\n    %s\n\n", nextCommand(c, baseDir))
+		}
+		if err != nil {
+			fatal(err)
+		}
+		return err
+	},
+}
+
+var PlanCommand = cli.Command{
+	Name:  "plan",
+	Usage: "print conversion plan without touching the repo",
+	Description: `'plan' computes the conversion strategy the same way 'convert' does
+and prints which mounts are skipped, which are copied, which directories would
+be moved and the resulting datastore_spec. Nothing in the repo is modified.
+
+IPFS_PATH environmental variable is respected
+	`,
+	Flags: []cli.Flag{
+		renameMountsFlag,
+		toProfileFlag,
+		toSpecFlag,
 	},
 	Action: func(c *cli.Context) error {
 		baseDir, err := getBaseDir()
@@ -76,7 +301,12 @@ IPFS_PATH environmental variable is respected
 			convert.Log.Fatal(err)
 		}
 
-		err = convert.Convert(baseDir, c.Bool("keep"))
+		opts, err := planOptions(c)
+		if err != nil {
+			convert.Log.Fatal(err)
+		}
+
+		err = convert.PlanWithOptions(baseDir, opts, os.Stdout)
 		if err != nil {
 			convert.Log.Fatal(err)
 		}
@@ -91,6 +321,15 @@ var RevertCommand = cli.Command{
 It's possible to run revert when conversion failed in middle of the process or
 if it was run with --keep option enabled.
 
+Repo config is restored to the state from before conversion, with
+Datastore.Spec matching the restored datastore_spec.
+
+Some conversion steps, like removing the old datastore, can't be undone
+automatically. Revert stops at such step and prints instructions on how to
+handle it by hand. Once done run revert again with --ack-manual to continue.
+--ack-manual confirms only the step revert stopped at, when revert reaches
+another manual step it stops again with its instructions.
+
 Note that in some cases revert may fail in a non-graceful way. When running
 revert after other programs used the datastore (like ipfs daemon), changes made
 by it between 'convert' and 'revert' may be lost. This may lead to repo
@@ -109,8 +348,13 @@ IPFS_PATH environmental variable is respected
 		},
 		cli.BoolFlag{
 			Name:  "fix-config",
-			Usage: "revert repo config from datastore_spec",
+			Usage: "rewrite Datastore.Spec in repo config from datastore_spec after revert, config is restored automatically for conversions run by this version",
+		},
+		cli.BoolFlag{
+			Name:  "ack-manual",
+			Usage: "continue past steps which had to be done by hand, confirming they were done",
 		},
+		breakLockFlag,
 	},
 	Action: func(c *cli.Context) error {
 		baseDir, err := getBaseDir()
@@ -118,9 +362,14 @@ IPFS_PATH environmental variable is respected
 			convert.Log.Fatal(err)
 		}
 
-		err = revert.Revert(baseDir, c.Bool("force"), c.Bool("fix-config"), false)
+		err = revert.RevertWithOptions(baseDir, revert.Options{
+			Force:     c.Bool("force"),
+			FixSpec:   c.Bool("fix-config"),
+			AckManual: c.Bool("ack-manual"),
+			BreakLock: c.Bool("break-lock"),
+		})
 		if err != nil {
-			convert.Log.Fatal(err)
+			fatal(err)
 		}
 		return err
 	},
@@ -134,22 +383,230 @@ was run.
 
 IPFS_PATH environmental variable is respected
 	`,
-	Flags: []cli.Flag{},
+	Flags: []cli.Flag{
+		breakLockFlag,
+	},
 	Action: func(c *cli.Context) error {
 		baseDir, err := getBaseDir()
 		if err != nil {
 			convert.Log.Fatal(err)
 		}
 
-		err = revert.Revert(baseDir, c.Bool("force"), false, true)
+		err = revert.RevertWithOptions(baseDir, revert.Options{
+			Force:     c.Bool("force"),
+			Cleanup:   true,
+			BreakLock: c.Bool("break-lock"),
+		})
 		if err != nil {
-			convert.Log.Fatal(err)
+			fatal(err)
 		}
 		return err
 	},
 }
 
-//TODO: Patch config util command
+var StatusCommand = cli.Command{
+	Name:  "status",
+	Usage: "print conversion state of the repo",
+	Description: `'status' reads convertlog and tells whether the last conversion finished,
+failed while copying keys, finished with --keep or needs manual intervention.
+Leftover temp datastores, spec and config backups are listed and the command to run
+next is suggested. Nothing in the repo is modified.
+
+IPFS_PATH environmental variable is respected
+	`,
+	Action: func(c *cli.Context) error {
+		baseDir, err := getBaseDir()
+		if err != nil {
+			convert.Log.Fatal(err)
+		}
+
+		s, err := revert.GetStatus(baseDir)
+		if err != nil {
+			convert.Log.Fatal(err)
+		}
+
+		printStatus(os.Stdout, baseDir, s)
+		return nil
+	},
+}
+
+func printStatus(w io.Writer, baseDir string, s *revert.Status) {
+	fmt.Fprintf(w, "Repo: %s\n", baseDir)
+	fmt.Fprintf(w, "State: %s\n", s.State)
+
+	switch s.State {
+	case revert.StateClean:
+		fmt.Fprintf(w, "No conversion in progress\n")
+	case revert.StateRunning:
+		fmt.Fprintf(w, "Repo is locked, conversion (or other program using the repo) is running\n")
+	case revert.StateFailedCopy:
+		fmt.Fprintf(w, "Conversion stopped while copying keys, %d keys copied to %s\n", s.Checkpoint.Count, s.Checkpoint.Dir)
+	case revert.StateFailed:
+		fmt.Fprintf(w, "Conversion failed, %d steps recorded in %s\n", s.Steps, revert.ConvertLog)
+	case revert.StateDoneWithBackup:
+		fmt.Fprintf(w, "Conversion finished, backup files were kept\n")
+	case revert.StateManual:
+		fmt.Fprintf(w, "Conversion failed after steps which can't be reverted automatically\n")
+	}
+
+	if len(s.Manual) > 0 {
+		fmt.Fprintf(w, "\nSteps to undo by hand before running revert --ack-manual:\n")
+		for _, m := range s.Manual {
+			fmt.Fprintf(w, "  %s\n", m.Message)
+			fmt.Fprintf(w, "    %s\n", strings.ReplaceAll(m.Instructions, "\n", "\n    "))
+		}
+	}
+
+	if len(s.Leftovers) > 0 {
+		fmt.Fprintf(w, "\nLeftover files:\n")
+		for _, l := range s.Leftovers {
+			fmt.Fprintf(w, "  %s\n", l)
+		}
+
+		if s.State == revert.StateClean {
+			fmt.Fprintf(w, "These are not referenced by %s, check and remove them by hand\n", revert.ConvertLog)
+		}
+	}
+
+	if s.Next != "" {
+		fmt.Fprintf(w, "\nNext: %s\n", s.Next)
+	}
+	if s.State == revert.StateFailedCopy || s.State == revert.StateDoneWithBackup {
+		fmt.Fprintf(w, "To go back to the old datastore run: ipfs-ds-convert revert")
+		if s.State == revert.StateDoneWithBackup {
+			fmt.Fprintf(w, " --force")
+		}
+		fmt.Fprintf(w, "\n")
+	}
+}
+
+var ConfigCommand = cli.Command{
+	Name:  "config",
+	Usage: "modify repo config",
+	Subcommands: []cli.Command{
+		ConfigSetSpecCommand,
+	},
+}
+
+var ConfigSetSpecCommand = cli.Command{
+	Name:      "set-spec",
+	Usage:     "write datastore spec to convert to into repo config",
+	ArgsUsage: "[spec file]",
+	Description: `'set-spec' sets Datastore.Spec in repo config to the spec read from the
+given JSON file, or to a named profile with --profile. The spec is validated, the
+old config is saved to a backup file next to it and the new config is written
+atomically. Run 'convert' afterwards to convert the datastore.
+
+set-spec refuses to run while convertlog exists in the repo, see 'status'.
+
+IPFS_PATH environmental variable is respected
+	`,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "profile, preset",
+			Usage: "use named datastore profile instead of a file: " + strings.Join(config.ProfileNames(), ", "),
+		},
+		breakLockFlag,
+	},
+	Action: func(c *cli.Context) error {
+		baseDir, err := getBaseDir()
+		if err != nil {
+			convert.Log.Fatal(err)
+		}
+
+		var spec map[string]interface{}
+		switch {
+		case c.IsSet("profile") && c.NArg() == 0:
+			spec, err = config.Profile(c.String("profile"))
+		case !c.IsSet("profile") && c.NArg() == 1:
+			spec = make(map[string]interface{})
+			err = config.Load(c.Args().First(), &spec)
+		default:
+			err = errors.New("expected spec file or --profile")
+		}
+		if err != nil {
+			convert.Log.Fatal(err)
+		}
+
+		backup, err := convert.SetSpecWithOptions(baseDir, spec, convert.Options{BreakLock: c.Bool("break-lock")})
+		if err != nil {
+			fatal(err)
+		}
+
+		convert.Log.Printf("Datastore.Spec updated, old config saved to %s", backup)
+		convert.Log.Printf("Run 'ipfs-ds-convert plan' to review the conversion")
+		return nil
+	},
+}
+
+// interruptContext returns a context which is cancelled on first SIGINT or
+// SIGTERM, second signal exits immediately
+func interruptContext() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case <-sigs:
+		case <-ctx.Done():
+			return
+		}
+
+		convert.Log.Println("Interrupted, finishing current step. Interrupt again to exit immediately")
+		cancel()
+
+		<-sigs
+		convert.Log.Fatal("Exiting without finishing current step, run 'ipfs-ds-convert revert'")
+	}()
+
+	return ctx, func() {
+		signal.Stop(sigs)
+		cancel()
+	}
+}
+
+// nextCommand returns command which continues interrupted conversion, or
+// reverts it when it can't be resumed
+func nextCommand(c *cli.Context, baseDir string) string {
+	if _, err := revert.LoadCheckpoint(baseDir); err != nil {
+		return "ipfs-ds-convert revert"
+	}
+
+	args := []string{"ipfs-ds-convert", "convert", "--resume"}
+	for _, name := range []string{"keep", "ignore-space", "rename-mounts"} {
+		if c.Bool(name) {
+			args = append(args, "--"+name)
+		}
+	}
+
+	if c.IsSet("workers") {
+		args = append(args, "--workers="+strconv.Itoa(c.Int("workers")))
+	}
+
+	if c.IsSet("verify") {
+		args = append(args, fmt.Sprintf("--verify=%s", c.String("verify")))
+	}
+
+	if c.IsSet("to-profile") {
+		args = append(args, "--to-profile="+c.String("to-profile"))
+	}
+
+	if c.IsSet("to-spec") {
+		args = append(args, "--to-spec="+c.String("to-spec"))
+	}
+
+	if c.IsSet("report") {
+		args = append(args, "--report="+c.String("report"))
+	}
+
+	if c.IsSet("log-format") {
+		args = append(args, "--log-format="+c.String("log-format"))
+	}
+
+	return strings.Join(args, " ")
+}
 
 func getBaseDir() (string, error) {
 	baseDir := os.Getenv(EnvDir)
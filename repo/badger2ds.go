@@ -0,0 +1,106 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dgraph-io/badger/v2/options"
+	humanize "github.com/dustin/go-humanize"
+	badger2ds "github.com/ipfs/go-ds-badger2"
+)
+
+type badger2dsDatastoreConfig struct {
+	path         string
+	syncWrites   bool
+	truncate     bool
+	vlogFileSize int64
+	compression  options.CompressionType
+}
+
+// Badger2dsDatastoreConfig returns a configuration stub for a badger v2
+// datastore from the given parameters
+func Badger2dsDatastoreConfig(params map[string]interface{}) (DatastoreConfig, error) {
+	c := badger2dsDatastoreConfig{
+		syncWrites:   true,
+		truncate:     true,
+		vlogFileSize: badger2ds.DefaultOptions.ValueLogFileSize,
+		compression:  options.None,
+	}
+	var ok bool
+
+	c.path, ok = params["path"].(string)
+	if !ok {
+		return nil, errors.New("'path' field is missing or not string")
+	}
+
+	if sw, ok := params["syncWrites"]; ok {
+		c.syncWrites, ok = sw.(bool)
+		if !ok {
+			return nil, errors.New("'syncWrites' field was not a boolean")
+		}
+	}
+
+	if t, ok := params["truncate"]; ok {
+		c.truncate, ok = t.(bool)
+		if !ok {
+			return nil, errors.New("'truncate' field was not a boolean")
+		}
+	}
+
+	if vfs, ok := params["vlogFileSize"]; ok {
+		s, ok := vfs.(string)
+		if !ok {
+			return nil, errors.New("'vlogFileSize' field was not a string")
+		}
+
+		size, err := humanize.ParseBytes(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 'vlogFileSize': %s", err)
+		}
+		c.vlogFileSize = int64(size)
+	}
+
+	if cm, ok := params["compression"]; ok {
+		switch cm {
+		case "none":
+			c.compression = options.None
+		case "snappy":
+			c.compression = options.Snappy
+		case "zstd":
+			c.compression = options.ZSTD
+		default:
+			return nil, fmt.Errorf("unrecognized value for compression: %v", cm)
+		}
+	}
+
+	return &c, nil
+}
+
+func (c *badger2dsDatastoreConfig) DiskSpec() DiskSpec {
+	return map[string]interface{}{
+		"type": "badger2ds",
+		"path": c.path,
+	}
+}
+
+func (c *badger2dsDatastoreConfig) Create(path string) (Datastore, error) {
+	p := c.path
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(path, p)
+	}
+
+	err := os.MkdirAll(p, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	defopts := badger2ds.DefaultOptions
+	defopts.SyncWrites = c.syncWrites
+	defopts.Truncate = c.truncate
+	defopts.ValueLogFileSize = c.vlogFileSize
+	defopts.Compression = c.compression
+
+	return badger2ds.NewDatastore(p, &defopts)
+}
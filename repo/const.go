@@ -4,7 +4,7 @@ const (
 	LockFile   = "repo.lock"
 	ConfigFile = "config"
 	SpecsFile  = "datastore_spec"
+	ReportFile = "convert_report.json"
 
-	SupportedRepoVersion = 11
-	ToolVersion          = "0.6.0"
+	ToolVersion = "0.6.0"
 )
@@ -0,0 +1,160 @@
+package repo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	lock "github.com/ipfs/go-fs-lock"
+	"github.com/pkg/errors"
+)
+
+// ApiFile holds multiaddr of the API of running ipfs daemon
+const ApiFile = "api"
+
+// DaemonRunningError is returned when ipfs daemon or another program is
+// using the repo
+type DaemonRunningError struct {
+	Reason string
+}
+
+func (e *DaemonRunningError) Error() string {
+	return fmt.Sprintf("ipfs daemon or another program is using the repo: %s. Stop it before running ipfs-ds-convert", e.Reason)
+}
+
+// StaleLockError is returned when repo lock file was left by a program which
+// is no longer running
+type StaleLockError struct {
+	Path   string
+	Reason string
+}
+
+func (e *StaleLockError) Error() string {
+	return fmt.Sprintf("stale lock %s: %s. It's safe to remove it with --break-lock", e.Path, e.Reason)
+}
+
+// apiDialTimeout limits how long CheckDaemon waits for daemon API to accept
+// connection
+var apiDialTimeout = time.Second
+
+// CheckDaemon looks for signs of running ipfs daemon which repo.lock alone
+// doesn't catch: API listening on address from api file and lock files left
+// by other tools
+func CheckDaemon(repoPath string) error {
+	addr, err := ioutil.ReadFile(filepath.Join(repoPath, ApiFile))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err == nil {
+		hostPort, ok := dialAddr(strings.TrimSpace(string(addr)))
+		if ok {
+			conn, err := net.DialTimeout("tcp", hostPort, apiDialTimeout)
+			if err == nil {
+				conn.Close()
+				return &DaemonRunningError{Reason: fmt.Sprintf("API at %s accepts connections", strings.TrimSpace(string(addr)))}
+			}
+		}
+	}
+
+	return checkLockFile(repoPath)
+}
+
+// Lock runs CheckDaemon and takes repo lock
+func Lock(repoPath string) (io.Closer, error) {
+	err := CheckDaemon(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock, err := lock.Lock(repoPath, LockFile)
+	if errors.As(err, new(lock.LockedError)) {
+		return nil, &DaemonRunningError{Reason: err.Error()}
+	}
+	return unlock, err
+}
+
+// BreakLock removes stale repo lock file
+func BreakLock(repoPath string) error {
+	return os.Remove(filepath.Join(repoPath, LockFile))
+}
+
+// checkLockFile inspects repo.lock contents. Locks taken with fcntl leave
+// the file empty, non-empty lock files are written by portable locking with
+// PID of the owner
+func checkLockFile(repoPath string) error {
+	lockPath := filepath.Join(repoPath, LockFile)
+
+	data, err := ioutil.ReadFile(lockPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(data) == 0 {
+		return nil
+	}
+
+	var meta struct {
+		OwnerPID int
+	}
+	if json.Unmarshal(data, &meta) != nil || meta.OwnerPID == 0 {
+		return &StaleLockError{Path: lockPath, Reason: "lock file has unknown contents and can't be taken"}
+	}
+
+	if processAlive(meta.OwnerPID) {
+		return &DaemonRunningError{Reason: fmt.Sprintf("%s is held by process %d", lockPath, meta.OwnerPID)}
+	}
+
+	return &StaleLockError{Path: lockPath, Reason: fmt.Sprintf("owner process %d is no longer running", meta.OwnerPID)}
+}
+
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+
+	if runtime.GOOS == "windows" {
+		//FindProcess fails for processes which don't exist
+		return true
+	}
+
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// dialAddr converts multiaddr of tcp API to host:port on the local host
+func dialAddr(addr string) (string, bool) {
+	parts := strings.Split(addr, "/")
+	if len(parts) != 5 || parts[0] != "" || parts[3] != "tcp" {
+		return "", false
+	}
+
+	host := parts[2]
+	switch parts[1] {
+	case "ip4":
+		if host == "0.0.0.0" {
+			host = "127.0.0.1"
+		}
+	case "ip6":
+		if host == "::" {
+			host = "::1"
+		}
+	case "dns", "dns4", "dns6":
+	default:
+		return "", false
+	}
+
+	return net.JoinHostPort(host, parts[4]), true
+}
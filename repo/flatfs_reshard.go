@@ -0,0 +1,114 @@
+package repo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	flatfs "github.com/ipfs/go-ds-flatfs"
+	errors "github.com/pkg/errors"
+)
+
+const (
+	flatfsExtension = ".data"
+	flatfsTempDir   = ".temp"
+)
+
+// ReshardFlatfs moves files of the flatfs datastore at path into shard
+// directories of shardFunc and rewrites the SHARDING file. Files which are
+// already in place are left alone, so an interrupted reshard can be run again,
+// also with the previous shard function to undo it. progress, when not nil,
+// is called with the number of files moved so far
+func ReshardFlatfs(path string, shardFunc string, progress func(moved int)) (int, error) {
+	id, err := flatfs.ParseShardFunc(shardFunc)
+	if err != nil {
+		return 0, err
+	}
+	fun := id.Func()
+
+	shards, err := readDirNames(path)
+	if err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	for _, shard := range shards {
+		shardDir := filepath.Join(path, shard)
+
+		fi, err := os.Stat(shardDir)
+		if err != nil {
+			return moved, err
+		}
+		if !fi.IsDir() || shard == flatfsTempDir {
+			continue
+		}
+
+		names, err := readDirNames(shardDir)
+		if err != nil {
+			return moved, err
+		}
+
+		for _, name := range names {
+			if !strings.HasSuffix(name, flatfsExtension) {
+				continue
+			}
+
+			target := fun(strings.TrimSuffix(name, flatfsExtension))
+			if target == shard {
+				continue
+			}
+
+			targetDir := filepath.Join(path, target)
+			err := os.Mkdir(targetDir, 0755)
+			if err != nil && !os.IsExist(err) {
+				return moved, err
+			}
+
+			err = os.Rename(filepath.Join(shardDir, name), filepath.Join(targetDir, name))
+			if err != nil {
+				return moved, errors.Wrapf(err, "moving %s to shard %s", name, target)
+			}
+
+			moved++
+			if progress != nil {
+				progress(moved)
+			}
+		}
+
+		left, err := readDirNames(shardDir)
+		if err != nil {
+			return moved, err
+		}
+
+		if len(left) == 0 {
+			err = os.Remove(shardDir)
+			if err != nil {
+				return moved, err
+			}
+		}
+	}
+
+	for _, f := range []string{flatfs.SHARDING_FN, flatfs.README_FN, flatfs.DiskUsageFile} {
+		err = os.Remove(filepath.Join(path, f))
+		if err != nil && !os.IsNotExist(err) {
+			return moved, err
+		}
+	}
+
+	err = flatfs.WriteShardFunc(path, id)
+	if err != nil {
+		return moved, err
+	}
+
+	return moved, flatfs.WriteReadme(path, id)
+}
+
+func readDirNames(path string) ([]string, error) {
+	dir, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer dir.Close()
+
+	return dir.Readdirnames(-1)
+}
@@ -0,0 +1,41 @@
+package repo
+
+import "fmt"
+
+// VersionRange is a range of fsrepo versions conversion works with
+type VersionRange struct {
+	MinVersion int
+	MaxVersion int
+}
+
+// Versions lists fsrepo versions conversion works with. Datastores, the
+// datastore_spec file and Datastore.Spec in config didn't change between
+// these versions, so no per-version layout rules are needed. Migrations only
+// touched other parts of config and keys stored in the datastore, which are
+// copied as they are
+var Versions = []VersionRange{
+	//go-ipfs 0.4.11 to 0.11
+	{MinVersion: 6, MaxVersion: 11},
+
+	//go-ipfs 0.12 and kubo, blocks are keyed by multihash instead of CID
+	{MinVersion: 12, MaxVersion: 15},
+}
+
+// CheckVersion returns an error naming the migration to run when repo
+// version isn't supported
+func CheckVersion(version int) error {
+	for _, r := range Versions {
+		if version >= r.MinVersion && version <= r.MaxVersion {
+			return nil
+		}
+	}
+
+	min := Versions[0].MinVersion
+	max := Versions[len(Versions)-1].MaxVersion
+
+	if version < min {
+		return fmt.Errorf("unsupported fsrepo version: %d, supported versions are %d to %d. Migrate the repo with 'fs-repo-migrations -to %d' or by running newer ipfs daemon with --migrate", version, min, max, min)
+	}
+
+	return fmt.Errorf("unsupported fsrepo version: %d, supported versions are %d to %d. Use newer ipfs-ds-convert or revert the repo with 'fs-repo-migrations -to %d -revert-ok'", version, min, max, max)
+}
@@ -0,0 +1,248 @@
+package revert
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/ipfs/ipfs-ds-convert/repo"
+)
+
+// LogFormat is the version of convertlog format written by this tool. Logs
+// of version 0, written by older versions of the tool, have no header and no
+// entry checksums. They are migrated when revert rewrites them
+const LogFormat = 1
+
+// Header is the first line of convertlog
+type Header struct {
+	Format int    `json:"format"`
+	Tool   string `json:"tool"`
+	Repo   string `json:"repo"`
+
+	// Strategy is Id() of the conversion strategy, empty when not known
+	Strategy string `json:"strategy,omitempty"`
+}
+
+func newHeader(repoPath string, strategyId string) *Header {
+	return &Header{
+		Format:   LogFormat,
+		Tool:     repo.ToolVersion,
+		Repo:     repoPath,
+		Strategy: strategyId,
+	}
+}
+
+// Line encodes header as convertlog line
+func (h *Header) Line() ([]byte, error) {
+	b, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+
+	return withChecksum(b), nil
+}
+
+// withChecksum appends checksum of data and newline to data. Checksum is
+// separated by space so that the line starts with plain JSON
+func withChecksum(data []byte) []byte {
+	return append(data, fmt.Sprintf(" %08x\n", crc32.ChecksumIEEE(data))...)
+}
+
+// splitChecksum splits line into JSON and checksum, sum is empty if line has
+// no checksum
+func splitChecksum(line string) (data string, sum string) {
+	line = strings.TrimSuffix(line, "\n")
+
+	i := strings.LastIndex(line, " ")
+	if i < 0 || len(line)-i-1 != 8 || !strings.HasSuffix(line[:i], "}") {
+		return line, ""
+	}
+
+	if _, err := hex.DecodeString(line[i+1:]); err != nil {
+		return line, ""
+	}
+
+	return line[:i], line[i+1:]
+}
+
+// LoadHeader reads header of convertlog in repo, it's nil for logs written by
+// older versions of the tool
+func LoadHeader(repo string) (*Header, error) {
+	h, _, err := readLog(repo)
+	return h, err
+}
+
+// readHeader reads only the first line of convertlog in repo
+func readHeader(repo string) (*Header, error) {
+	f, err := os.Open(path.Join(repo, ConvertLog))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if err := checkFormat(line); err != nil {
+		return nil, err
+	}
+
+	data, err := decodeLine(line, 1, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	_, h, err := parseLine(data, line, 1)
+	return h, err
+}
+
+func loadLog(repo string) (Steps, error) {
+	_, steps, err := readLog(repo)
+	return steps, err
+}
+
+// readLog parses convertlog in repo. Errors name the corrupted line
+func readLog(repo string) (*Header, Steps, error) {
+	var header *Header
+	var steps []Step
+
+	f, err := os.Open(path.Join(repo, ConvertLog))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	log := bufio.NewReader(f)
+	var readErr error
+	for n := 1; ; n++ {
+		var line string
+		line, readErr = log.ReadString('\n')
+
+		if line == "" {
+			break
+		}
+
+		if n == 1 {
+			//newer formats may change the way entries are checksummed, check
+			//the version before anything else
+			if err := checkFormat(line); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		data, err := decodeLine(line, n, header)
+		if err != nil && readErr == io.EOF && !strings.HasSuffix(line, "\n") {
+			//last line was cut off by a crash while appending it, treat it
+			//as never written
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		step, h, err := parseLine(data, line, n)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if h != nil {
+			header = h
+		} else {
+			steps = append(steps, *step)
+		}
+
+		if readErr != nil {
+			break
+		}
+	}
+
+	if readErr != io.EOF {
+		return nil, nil, readErr
+	}
+
+	return header, steps, nil
+}
+
+// checkFormat refuses logs written in format newer than LogFormat
+func checkFormat(line string) error {
+	data, _ := splitChecksum(line)
+
+	h := &Header{}
+	if json.Unmarshal([]byte(data), h) != nil {
+		return nil
+	}
+
+	if h.Format > LogFormat {
+		return fmt.Errorf("%s was written by ipfs-ds-convert %s in format %d, this version only supports format %d, use newer version", ConvertLog, h.Tool, h.Format, LogFormat)
+	}
+
+	return nil
+}
+
+// decodeLine verifies checksum of line n of convertlog and decodes its JSON.
+// Lines without checksum are only accepted in logs without header
+func decodeLine(line string, n int, header *Header) (map[string]interface{}, error) {
+	data, sum := splitChecksum(line)
+
+	if sum != "" {
+		if fmt.Sprintf("%08x", crc32.ChecksumIEEE([]byte(data))) != sum {
+			return nil, fmt.Errorf("%s line %d is corrupted: checksum mismatch: %s", ConvertLog, n, strings.TrimSuffix(line, "\n"))
+		}
+	} else if header != nil {
+		return nil, fmt.Errorf("%s line %d is corrupted: missing checksum: %s", ConvertLog, n, strings.TrimSuffix(line, "\n"))
+	}
+
+	stepJson := map[string]interface{}{}
+	err := json.Unmarshal([]byte(data), &stepJson)
+	if err != nil {
+		return nil, fmt.Errorf("%s line %d is corrupted: %s", ConvertLog, n, err)
+	}
+
+	return stepJson, nil
+}
+
+// parseLine parses decoded line n of convertlog, returning either step or
+// header
+func parseLine(stepJson map[string]interface{}, line string, n int) (*Step, *Header, error) {
+	if _, ok := stepJson["format"]; ok && n == 1 {
+		data, _ := splitChecksum(line)
+		h := &Header{}
+		err := json.Unmarshal([]byte(data), h)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s line %d: invalid header: %s", ConvertLog, n, err)
+		}
+
+		return nil, h, nil
+	}
+
+	action, ok := stepJson["action"].(string)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s line %d: invalid action type in convert steps: %s", ConvertLog, n, line)
+	}
+
+	rawArgs, ok := stepJson["arg"].([]interface{})
+	var args []string
+	if ok {
+		args = make([]string, 0, len(rawArgs))
+		for i := range rawArgs {
+			arg, ok := rawArgs[i].(string)
+			if !ok {
+				return nil, nil, fmt.Errorf("%s line %d: invalid arg %d in convert steps: %s", ConvertLog, n, i, line)
+			}
+			args = append(args, arg)
+		}
+	}
+
+	return &Step{
+		action: Action(action),
+		arg:    args,
+	}, nil, nil
+}
@@ -0,0 +1,129 @@
+package revert
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestLogHeader(t *testing.T) {
+	dname, _ := ioutil.TempDir(os.TempDir(), "ds-convert-test-")
+	defer os.RemoveAll(dname)
+
+	lg, err := NewActionLogger(dname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lg.Close()
+
+	err = lg.SetStrategy("copy-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = lg.Log(ActionRemove, "/tmp/ds-convert1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = lg.SetStrategy("copy-2")
+	if err == nil || !strings.Contains(err.Error(), "actions were already logged") {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	h, err := LoadHeader(dname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if h == nil || h.Format != LogFormat || h.Repo != dname || h.Strategy != "copy-1" {
+		t.Errorf("unexpected header %v", h)
+	}
+
+	cp, err := LoadCheckpoint(dname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cp.Dir != "/tmp/ds-convert1" || cp.Strategy != "copy-1" {
+		t.Errorf("unexpected checkpoint %v", cp)
+	}
+}
+
+func TestLoadCorruptedLog(t *testing.T) {
+	dname, _ := ioutil.TempDir(os.TempDir(), "ds-convert-test-")
+	defer os.RemoveAll(dname)
+
+	h, _ := newHeader(dname, "").Line()
+	rm, _ := ActionRemove.Line("/a")
+	mv, _ := ActionMove.Line("/b", "/c")
+
+	corrupted := strings.Replace(string(mv), "/b", "/x", 1)
+	_ = ioutil.WriteFile(path.Join(dname, ConvertLog), []byte(string(h)+string(rm)+corrupted), 0600)
+
+	_, err := loadLog(dname)
+	if err == nil || !strings.Contains(err.Error(), "convertlog line 3 is corrupted: checksum mismatch") {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	_ = ioutil.WriteFile(path.Join(dname, ConvertLog), []byte(string(h)+`{"action":"rm","arg":["/a"]}`+"\n"+string(mv)), 0600)
+
+	_, err = loadLog(dname)
+	if err == nil || !strings.Contains(err.Error(), "convertlog line 2 is corrupted: missing checksum") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestLoadNewerLog(t *testing.T) {
+	dname, _ := ioutil.TempDir(os.TempDir(), "ds-convert-test-")
+	defer os.RemoveAll(dname)
+
+	_ = ioutil.WriteFile(path.Join(dname, ConvertLog), []byte(`{"format":2,"tool":"9.0.0","repo":"/a"} 00000000
+{"action":"rm","arg":["/a"]} 00000000
+`), 0600)
+
+	_, err := loadLog(dname)
+	if err == nil || !strings.Contains(err.Error(), "written by ipfs-ds-convert 9.0.0 in format 2, this version only supports format 1") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestMigrateLegacyLog(t *testing.T) {
+	dname, _ := ioutil.TempDir(os.TempDir(), "ds-convert-test-")
+	defer os.RemoveAll(dname)
+
+	_ = ioutil.WriteFile(path.Join(dname, ConvertLog), []byte(`{"action":"rm","arg":["/a"]}
+{"action":"mv","arg":["/b","/c"]}
+`), 0600)
+
+	h, steps, err := readLog(dname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if h != nil || len(steps) != 2 {
+		t.Fatalf("unexpected log %v %v", h, steps)
+	}
+
+	err = steps.pop(dname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, steps, err = readLog(dname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if h == nil || h.Format != LogFormat || len(steps) != 1 || steps[0].action != ActionRemove {
+		t.Fatalf("unexpected log %v %v", h, steps)
+	}
+
+	b, _ := ioutil.ReadFile(path.Join(dname, ConvertLog))
+	rm, _ := ActionRemove.Line("/a")
+	if !strings.HasSuffix(string(b), string(rm)) {
+		t.Errorf("log not migrated: %s", b)
+	}
+}
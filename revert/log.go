@@ -1,10 +1,15 @@
 package revert
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path"
+	"strconv"
+
+	"github.com/ipfs/ipfs-ds-convert/config"
 )
 
 const (
@@ -20,6 +25,17 @@ const (
 
 	//ActionManual marks backup files that can be cleaned up after conversion with --keep
 	ActionCleanup = Action("cleanup")
+
+	//ActionCheckpoint records copy progress, used by convert --resume
+	ActionCheckpoint = Action("checkpoint")
+
+	//ActionReshard moves files of flatfs datastore back to shards of the given
+	//shard function
+	ActionReshard = Action("reshard")
+
+	//ActionRestore atomically replaces a file with its backup, args are
+	//[backup, path]
+	ActionRestore = Action("restore")
 )
 
 type Action string
@@ -36,17 +52,158 @@ func NewActionLogger(repoPath string) (*ActionLogger, error) {
 		return nil, fmt.Errorf("Log file %s already exists, you may want to run revert", path.Join(repoPath, ConvertLog))
 	}
 
+	header, err := newHeader(repoPath, "").Line()
+	if err != nil {
+		return nil, err
+	}
+
 	f, err := os.Create(path.Join(repoPath, ConvertLog))
 	if err != nil {
 		return nil, err
 	}
 
+	_, err = f.Write(header)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	err = config.SyncDir(repoPath)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
 	return &ActionLogger{
 		repo: repoPath,
 		file: f,
 	}, nil
 }
 
+// OpenActionLogger opens existing revert log for appending, used when resuming
+// interrupted conversion
+func OpenActionLogger(repoPath string) (*ActionLogger, error) {
+	err := repairTail(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path.Join(repoPath, ConvertLog), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ActionLogger{
+		repo: repoPath,
+		file: f,
+	}, nil
+}
+
+// repairTail makes convertlog end with a complete line, so that lines appended
+// to it aren't glued to a line cut off by a crash. Cut off line is removed
+// unless readLog accepted it, in which case only its newline was lost
+func repairTail(repoPath string) error {
+	logPath := path.Join(repoPath, ConvertLog)
+
+	header, steps, err := readLog(repoPath)
+	if err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(logPath)
+	if err != nil {
+		return err
+	}
+
+	if len(data) == 0 || data[len(data)-1] == '\n' {
+		return nil
+	}
+
+	accepted := len(steps)
+	if header != nil {
+		accepted++
+	}
+
+	if accepted > bytes.Count(data, []byte{'\n'}) {
+		data = append(data, '\n')
+	} else {
+		data = data[:bytes.LastIndexByte(data, '\n')+1]
+	}
+
+	return config.WriteFileAtomic(logPath, data, 0600)
+}
+
+// SetStrategy records strategy id in header of log, it must be called before
+// any action is logged
+func (a *ActionLogger) SetStrategy(strategyId string) error {
+	if a == nil {
+		return nil
+	}
+
+	header, err := newHeader(a.repo, strategyId).Line()
+	if err != nil {
+		return err
+	}
+
+	logPath := path.Join(a.repo, ConvertLog)
+	steps, err := loadLog(a.repo)
+	if err != nil {
+		return err
+	}
+
+	if len(steps) != 0 {
+		return fmt.Errorf("can't set strategy in %s, actions were already logged", logPath)
+	}
+
+	err = config.WriteFileAtomic(logPath, header, 0600)
+	if err != nil {
+		return err
+	}
+
+	return a.reopen()
+}
+
+// Checkpoint records copy progress of datastore in dir. Checkpoint logged
+// right before for the same dir is replaced, so that the log holds only the
+// latest one instead of a line per copied batch
+func (a *ActionLogger) Checkpoint(dir string, key string, n int) error {
+	if a == nil {
+		return nil
+	}
+
+	steps, err := loadLog(a.repo)
+	if err != nil {
+		return err
+	}
+
+	step := Step{action: ActionCheckpoint, arg: []string{dir, key, strconv.Itoa(n)}}
+
+	top := steps.top()
+	if top.action != ActionCheckpoint || len(top.arg) != 3 || top.arg[0] != dir {
+		return a.Log(step.action, step.arg...)
+	}
+
+	steps[len(steps)-1] = step
+	err = steps.write(a.repo)
+	if err != nil {
+		return err
+	}
+
+	return a.reopen()
+}
+
+// reopen opens convertlog again after it was replaced by atomic rewrite
+func (a *ActionLogger) reopen() error {
+	var err error
+
+	a.file.Close()
+	a.file, err = os.OpenFile(path.Join(a.repo, ConvertLog), os.O_WRONLY|os.O_APPEND, 0)
+	return err
+}
+
 func (a *ActionLogger) Log(action Action, params ...string) error {
 	if a == nil {
 		return nil
@@ -79,6 +236,7 @@ func (a *ActionLogger) CloseFinal() error {
 	return os.Remove(path.Join(a.repo, ConvertLog))
 }
 
+// Line encodes action as convertlog line, JSON followed by its checksum
 func (a Action) Line(arg ...string) ([]byte, error) {
 	b, err := json.Marshal(map[string]interface{}{
 		"action": a,
@@ -89,5 +247,5 @@ func (a Action) Line(arg ...string) ([]byte, error) {
 		return nil, err
 	}
 
-	return append(b, "\n"...), nil
+	return withChecksum(b), nil
 }
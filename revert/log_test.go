@@ -63,3 +63,111 @@ func TestLog(t *testing.T) {
 		t.Errorf("unexpected revert log, got: `%s`", string(b))
 	}
 }
+
+func TestCheckpoint(t *testing.T) {
+	d, err := ioutil.TempDir(os.TempDir(), "ds-convert-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(d)
+
+	lg, err := revert.NewActionLogger(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = lg.Log(revert.ActionRemove, "/tmp/ds-convert1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= 100; i++ {
+		err = lg.Checkpoint("/tmp/ds-convert1", "/k", i)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//steps logged after checkpoint are appended to the new log
+	err = lg.Log(revert.ActionMkdir, "/tmp/ds-convert2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lg.Close()
+
+	b, err := ioutil.ReadFile(path.Join(d, revert.ConvertLog))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := strings.Count(string(b), `"action":"checkpoint"`); n != 1 {
+		t.Fatalf("expected 1 checkpoint in log, got %d: `%s`", n, string(b))
+	}
+
+	if !strings.Contains(string(b), `{"action":"checkpoint","arg":["/tmp/ds-convert1","/k","100"]}`) ||
+		!strings.Contains(string(b), `{"action":"mkdir","arg":["/tmp/ds-convert2"]}`) {
+		t.Errorf("unexpected revert log, got: `%s`", string(b))
+	}
+}
+
+func TestOpenActionLoggerTornLine(t *testing.T) {
+	d, err := ioutil.TempDir(os.TempDir(), "ds-convert-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(d)
+
+	lg, err := revert.NewActionLogger(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = lg.Log(revert.ActionRemove, "/tmp/ds-convert1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lg.Close()
+
+	//simulate crash while appending first checkpoint
+	line, err := revert.ActionCheckpoint.Line("/tmp/ds-convert1", "/a", "10")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.OpenFile(path.Join(d, revert.ConvertLog), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.Write(line[:len(line)/2])
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cp, err := revert.LoadCheckpoint(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Key != "" {
+		t.Fatalf("expected no committed key, got %s", cp.Key)
+	}
+
+	lg, err = revert.OpenActionLogger(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = lg.Checkpoint("/tmp/ds-convert1", "/b", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lg.Close()
+
+	cp, err = revert.LoadCheckpoint(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Key != "/b" || cp.Count != 20 {
+		t.Errorf("unexpected checkpoint %v", cp)
+	}
+}
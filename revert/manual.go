@@ -0,0 +1,102 @@
+package revert
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/ipfs/ipfs-ds-convert/repo"
+)
+
+// ManualStep is a convertlog step which revert can't undo
+type ManualStep struct {
+	// Message is the step description logged by convert
+	Message string
+
+	// Instructions tell how to undo the step by hand
+	Instructions string
+}
+
+// ManualStepError is returned by revert when it reaches a step it can't undo.
+// The step stays in convertlog until revert is run with AckManual
+type ManualStepError struct {
+	ManualStep
+}
+
+func (e *ManualStepError) Error() string {
+	return fmt.Sprintf("revert stopped at manual step '%s'\n%s\nOnce done run 'ipfs-ds-convert revert --ack-manual' to continue", e.Message, e.Instructions)
+}
+
+// manualSteps lists manual steps in steps in the order revert reaches them
+func manualSteps(repoPath string, steps Steps) []ManualStep {
+	var out []ManualStep
+	for i := len(steps) - 1; i >= 0; i-- {
+		if steps[i].action == ActionManual && len(steps[i].arg) > 0 {
+			out = append(out, manualStep(repoPath, steps, i))
+		}
+	}
+
+	return out
+}
+
+// manualStep describes manual step at index i of steps. Instructions may refer
+// to steps revert runs after it
+func manualStep(repoPath string, steps Steps, i int) ManualStep {
+	message := steps[i].arg[0]
+	step := ManualStep{Message: message}
+
+	switch message {
+	case "restore datastore_spec to previous state":
+		step.Instructions = fmt.Sprintf("%s was replaced with the spec of the new datastore and no backup was kept. "+
+			"Write the spec of the old datastore into it, or restore it from your own backup of the repo.",
+			filepath.Join(repoPath, repo.SpecsFile))
+	case "no backup data present for revert":
+		moves := removedMoves(steps[:i])
+		if len(moves) == 0 {
+			step.Instructions = "The old datastore was removed after the new one was verified, revert can't bring it back. " +
+				"Restore the old datastore directories from your own backup of the repo, " +
+				"or keep the new datastore and don't continue the revert."
+			break
+		}
+
+		var b strings.Builder
+		b.WriteString("The old datastore was removed after the new one was verified, revert can't bring it back. " +
+			"Restore the old datastore directories from your own backup of the repo to the paths revert moves them back from:")
+		for _, m := range moves {
+			fmt.Fprintf(&b, "\n  backup of %s -> %s", m.arg[1], m.arg[0])
+		}
+		b.WriteString("\nOr keep the new datastore and don't continue the revert.")
+		step.Instructions = b.String()
+	default:
+		step.Instructions = "Revert can't undo this step, do it by hand."
+	}
+
+	return step
+}
+
+// removedMoves returns moves in steps whose source is inside a directory
+// removed by cleanup when conversion finished
+func removedMoves(steps Steps) []Step {
+	var removed []string
+	for _, step := range steps {
+		if step.action == ActionCleanup && len(step.arg) == 1 {
+			removed = append(removed, step.arg[0])
+		}
+	}
+
+	var out []Step
+	for _, step := range steps {
+		if step.action != ActionMove || len(step.arg) != 2 {
+			continue
+		}
+
+		for _, dir := range removed {
+			if strings.HasPrefix(step.arg[0], dir+string(filepath.Separator)) {
+				out = append(out, step)
+				break
+			}
+		}
+	}
+
+	return out
+}
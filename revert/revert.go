@@ -9,31 +9,74 @@ import (
 
 	"github.com/ipfs/ipfs-ds-convert/repo"
 
-	"encoding/json"
-	lock "github.com/ipfs/go-fs-lock"
 	"github.com/ipfs/ipfs-ds-convert/config"
 	"github.com/pkg/errors"
-	"io/ioutil"
 )
 
 var Log = logging.New(os.Stderr, "revert ", logging.LstdFlags)
 
 type process struct {
-	repo  string
-	force bool
+	repo      string
+	force     bool
+	ackManual bool
 
 	steps Steps
 }
 
+// Options control revert
+type Options struct {
+	// Force reverts successful conversion
+	Force bool
+
+	// FixSpec rewrites Datastore.Spec in repo config from datastore_spec after
+	// revert
+	FixSpec bool
+
+	// Cleanup removes backup files of conversion run with --keep instead of
+	// reverting it
+	Cleanup bool
+
+	// AckManual confirms that the manual step revert stopped at, the one at
+	// the top of convertlog, was done by hand and lets revert continue past
+	// it. Manual steps further down have to be confirmed separately
+	AckManual bool
+
+	// BreakLock removes repo lock left by a program which is no longer
+	// running, see repo.StaleLockError
+	BreakLock bool
+}
+
 func Revert(repoPath string, force bool, fixSpec bool, cleanupMode bool) (err error) {
+	return RevertWithOptions(repoPath, Options{
+		Force:   force,
+		FixSpec: fixSpec,
+		Cleanup: cleanupMode,
+	})
+}
+
+// RevertWithOptions reverts or cleans up the last conversion in repo. When a
+// step which can't be undone automatically is reached *ManualStepError is
+// returned, unless opts.AckManual is set
+func RevertWithOptions(repoPath string, opts Options) (err error) {
 	//TODO: validate repo dir
 
+	fixSpec := opts.FixSpec
+	cleanupMode := opts.Cleanup
+
 	p := process{
 		repo:  repoPath,
-		force: force,
+		force: opts.Force,
 	}
 
-	unlock, err := lock.Lock(p.repo, repo.LockFile)
+	unlock, err := repo.Lock(p.repo)
+	var stale *repo.StaleLockError
+	if opts.BreakLock && errors.As(err, &stale) {
+		Log.Printf("Removing stale lock %s: %s", stale.Path, stale.Reason)
+		err = repo.BreakLock(p.repo)
+		if err == nil {
+			unlock, err = repo.Lock(p.repo)
+		}
+	}
 	if err != nil {
 		return err
 	}
@@ -44,6 +87,13 @@ func Revert(repoPath string, force bool, fixSpec bool, cleanupMode bool) (err er
 		return err
 	}
 
+	if opts.AckManual {
+		p.ackManual = p.steps.top().action == ActionManual
+		if !p.ackManual {
+			Log.Println("No manual step at the top of convertlog, nothing to acknowledge")
+		}
+	}
+
 	if cleanupMode {
 		Log.Println("Start cleanup")
 	} else {
@@ -148,7 +198,52 @@ func (p *process) executeStep(step Step) error {
 
 		Log.Println("\\-> ok")
 
+	case ActionReshard:
+		if len(step.arg) != 2 {
+			return fmt.Errorf("revert reshard: arg count %d != 2", len(step.arg))
+		}
+		Log.Printf("reshard '%s' to '%s': ", step.arg[0], step.arg[1])
+
+		moved, err := repo.ReshardFlatfs(step.arg[0], step.arg[1], nil)
+		if err != nil {
+			return errors.Wrapf(err, "revert reshard of '%s'", step.arg[0])
+		}
+
+		Log.Printf("\\-> ok, %d files moved", moved)
+
+	case ActionRestore:
+		if len(step.arg) != 2 {
+			return fmt.Errorf("revert restore: arg count %d != 2", len(step.arg))
+		}
+		Log.Printf("restore '%s' from '%s': ", step.arg[1], step.arg[0])
+
+		if _, err := os.Stat(step.arg[0]); err != nil {
+			return errors.Wrapf(err, "revert restore: backup '%s'", step.arg[0])
+		}
+
+		//rename replaces the file atomically
+		err := os.Rename(step.arg[0], step.arg[1])
+		if err != nil {
+			return err
+		}
+
+		Log.Println("\\-> ok")
+
+	case ActionManual:
+		if len(step.arg) != 1 {
+			return fmt.Errorf("revert manual: arg count %d != 1", len(step.arg))
+		}
+
+		if !p.ackManual {
+			return &ManualStepError{manualStep(p.repo, p.steps, len(p.steps)-1)}
+		}
+
+		//operator saw instructions only for this step
+		p.ackManual = false
+		Log.Printf("manual step '%s' acknowledged", step.arg[0])
+
 	case ActionCleanup:
+	case ActionCheckpoint:
 	default:
 		return fmt.Errorf("unknown revert step '%s'", step.action)
 	}
@@ -166,6 +261,22 @@ func (p *process) executeCleanupStep(step Step, n int) error {
 	case ActionRemove:
 	case ActionMove:
 	case ActionMkdir:
+	case ActionCheckpoint:
+	case ActionReshard:
+	case ActionManual:
+
+	case ActionRestore:
+		if len(step.arg) != 2 {
+			return fmt.Errorf("cleanup restore arg count %d != 2", len(step.arg))
+		}
+		Log.Printf("cleanup '%s'", step.arg[0])
+
+		err := os.RemoveAll(step.arg[0])
+		if err != nil {
+			return err
+		}
+
+		Log.Println("\\-> ok")
 
 	case ActionCleanup:
 		if len(step.arg) != 1 {
@@ -199,38 +310,8 @@ func fixConfig(repoPath string) error {
 		return errors.Wrapf(err, "validating datastore_spec spec")
 	}
 
-	repoConfig := make(map[string]interface{})
-	err = config.Load(filepath.Join(repoPath, repo.ConfigFile), &repoConfig)
-	if err != nil {
-		return err
-	}
-
-	confDatastore, ok := repoConfig["Datastore"].(map[string]interface{})
-	if !ok {
-		return fmt.Errorf("invalid Datastore field in config")
-	}
-
-	confDatastore["Spec"] = spec
-
-	err = os.Rename(filepath.Join(repoPath, repo.ConfigFile), filepath.Join(repoPath, "config-old"))
-	if err != nil {
-		return err
-	}
-
-	confBytes, err := json.MarshalIndent(repoConfig, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	err = ioutil.WriteFile(filepath.Join(repoPath, repo.ConfigFile), []byte(confBytes), 0660)
-	if err != nil {
-		return err
-	}
-
 	//TODO: might try opening the datastore to soo if config works and revert to old
 	//config.
 
-	err = os.Remove(filepath.Join(repoPath, "config-old"))
-
-	return err
+	return config.WriteSpec(filepath.Join(repoPath, repo.ConfigFile), spec)
 }
@@ -1,11 +1,14 @@
 package revert_test
 
 import (
+	"context"
 	"io/ioutil"
+	"os"
 	"path"
 	"strings"
 	"testing"
 
+	"github.com/ipfs/ipfs-ds-convert/config"
 	"github.com/ipfs/ipfs-ds-convert/convert"
 	"github.com/ipfs/ipfs-ds-convert/repo"
 	"github.com/ipfs/ipfs-ds-convert/revert"
@@ -79,6 +82,102 @@ func TestBasicConvertRevertFix(t *testing.T) {
 	testutil.FinishTest(t, dir, s1, s2, 100, 100)
 }
 
+func TestMoveConvertRevert(t *testing.T) {
+	spec := make(map[string]interface{})
+	err := config.Load("../testfiles/levelSpec", &spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, _close := testutil.NewTestRepo(t, spec)
+	defer _close(t)
+
+	r, err := testutil.OpenRepo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seed, err := testutil.InsertRandomKeys("", 100, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.PatchConfig(t, path.Join(dir, "config"), "../testfiles/levelMovedSpec")
+
+	//Convert!
+	err = convert.Convert(dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = revert.Revert(dir, true, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s, err := os.Stat(path.Join(dir, "datastore")); err != nil || !s.IsDir() {
+		t.Errorf("expected datastore to be moved back, got %v", err)
+	}
+
+	if _, err := os.Stat(path.Join(dir, "leveldb")); !os.IsNotExist(err) {
+		t.Errorf("expected 'leveldb' directory to be moved back, got %v", err)
+	}
+
+	testutil.PatchConfig(t, path.Join(dir, "config"), "../testfiles/levelSpec")
+
+	r, err = testutil.OpenRepo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = testutil.Verify("", 100, seed, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReshardConvertRevert(t *testing.T) {
+	//Prepare repo
+	dir, _close, s1, s2 := testutil.PrepareTest(t, 1000, 1000)
+	defer _close(t)
+
+	testutil.PatchConfig(t, path.Join(dir, "config"), "../testfiles/reshardSpec")
+
+	//Convert!
+	err := convert.Convert(dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = revert.Revert(dir, true, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sharding, err := ioutil.ReadFile(path.Join(dir, "blocks", "SHARDING"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.TrimSpace(string(sharding)) != "/repo/flatfs/shard/v1/next-to-last/2" {
+		t.Errorf("unexpected SHARDING file content: %s", sharding)
+	}
+
+	testutil.PatchConfig(t, path.Join(dir, "config"), "../testfiles/defaultSpec")
+
+	testutil.FinishTest(t, dir, s1, s2, 1000, 1000)
+}
+
 func TestConvertRevertLocked(t *testing.T) {
 	//Prepare repo
 	dir, _close, _, _ := testutil.PrepareTest(t, 100, 100)
@@ -268,3 +367,205 @@ func TestRevertMoveChecks(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestToSpecConvertRevert(t *testing.T) {
+	//Prepare repo
+	dir, _close, s1, s2 := testutil.PrepareTest(t, 1000, 1000)
+	defer _close(t)
+
+	oldConfig, err := ioutil.ReadFile(path.Join(dir, "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spec, err := config.Profile("mixed")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = convert.ConvertWithOptions(context.Background(), dir, convert.Options{KeepBackup: true, ToSpec: spec})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.FinishTest(t, dir, s1, s2, 1000, 1000)
+
+	err = revert.Revert(dir, true, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newConfig, err := ioutil.ReadFile(path.Join(dir, "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(newConfig) != string(oldConfig) {
+		t.Errorf("config was not restored")
+	}
+
+	testutil.FinishTest(t, dir, s1, s2, 1000, 1000)
+}
+
+func TestConvertRevertRestoresConfig(t *testing.T) {
+	//Prepare repo
+	dir, _close, s1, s2 := testutil.PrepareTest(t, 100, 100)
+	defer _close(t)
+
+	testutil.PatchConfig(t, path.Join(dir, "config"), "../testfiles/badgerSpec")
+
+	err := convert.Convert(dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//no --fix-config, config must agree with datastore_spec after revert
+	err = revert.Revert(dir, true, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repoConfig := make(map[string]interface{})
+	if err := config.Load(path.Join(dir, "config"), &repoConfig); err != nil {
+		t.Fatal(err)
+	}
+
+	configId, err := repo.DatastoreSpec(repoConfig["Datastore"].(map[string]interface{})["Spec"].(map[string]interface{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	specId, err := ioutil.ReadFile(path.Join(dir, repo.SpecsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if configId != string(specId) {
+		t.Errorf("config spec %s doesn't match datastore_spec %s", configId, specId)
+	}
+
+	testutil.FinishTest(t, dir, s1, s2, 100, 100)
+}
+
+func TestRevertManualStep(t *testing.T) {
+	//Prepare repo
+	dir, _close, _, _ := testutil.PrepareTest(t, 10, 10)
+	defer _close(t)
+
+	tmpDir := path.Join(dir, "ds-convert123")
+	err := os.Mkdir(tmpDir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rm, _ := revert.ActionRemove.Line(tmpDir)
+	manual, _ := revert.ActionManual.Line("no backup data present for revert")
+
+	err = ioutil.WriteFile(path.Join(dir, revert.ConvertLog), append(rm, manual...), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = revert.Revert(dir, false, false, false)
+	merr, ok := err.(*revert.ManualStepError)
+	if !ok {
+		t.Fatalf("expected manual step error, got %v", err)
+	}
+
+	if merr.Message != "no backup data present for revert" || !strings.Contains(err.Error(), "revert --ack-manual") {
+		t.Errorf("unexpected error %s", err)
+	}
+
+	if _, err := os.Stat(path.Join(dir, revert.ConvertLog)); err != nil {
+		t.Fatalf("log removed after manual step: %s", err)
+	}
+
+	if _, err := os.Stat(tmpDir); err != nil {
+		t.Fatalf("revert went past manual step: %s", err)
+	}
+
+	err = revert.RevertWithOptions(dir, revert.Options{AckManual: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(tmpDir); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed, got %v", tmpDir, err)
+	}
+
+	if _, err := os.Stat(path.Join(dir, revert.ConvertLog)); !os.IsNotExist(err) {
+		t.Errorf("expected log to be removed, got %v", err)
+	}
+}
+
+func TestRevertRemovedBackup(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "ds-convert-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	oldDir := path.Join(dir, "ds-convert-old123")
+	newDir := path.Join(dir, "ds-convert123")
+
+	//new datastore in the repo, old one removed after conversion
+	if err := os.Mkdir(path.Join(dir, "blocks"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var log []byte
+	for _, step := range [][]string{
+		{string(revert.ActionRemove), newDir},
+		{string(revert.ActionRemove), oldDir},
+		{string(revert.ActionCleanup), oldDir},
+		{string(revert.ActionMove), path.Join(oldDir, "datastore"), path.Join(dir, "datastore")},
+		{string(revert.ActionMove), path.Join(dir, "blocks"), path.Join(newDir, "blocks")},
+		{string(revert.ActionMkdir), newDir},
+		{string(revert.ActionManual), "no backup data present for revert"},
+		{string(revert.ActionManual), "restore datastore_spec to previous state"},
+	} {
+		line, _ := revert.Action(step[0]).Line(step[1:]...)
+		log = append(log, line...)
+	}
+
+	err = ioutil.WriteFile(path.Join(dir, revert.ConvertLog), log, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = revert.RevertWithOptions(dir, revert.Options{AckManual: true})
+	merr, ok := err.(*revert.ManualStepError)
+	if !ok {
+		t.Fatalf("expected manual step error, got %v", err)
+	}
+
+	//only the step at the top of the log was acknowledged
+	if merr.Message != "no backup data present for revert" {
+		t.Fatalf("unexpected manual step %s", merr.Message)
+	}
+
+	expected := "backup of " + path.Join(dir, "datastore") + " -> " + path.Join(oldDir, "datastore")
+	if !strings.Contains(merr.Instructions, expected) {
+		t.Fatalf("expected '%s' in instructions, got:\n%s", expected, merr.Instructions)
+	}
+
+	//restore the backup as instructed
+	if err := os.MkdirAll(path.Join(oldDir, "datastore"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	err = revert.RevertWithOptions(dir, revert.Options{AckManual: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(path.Join(dir, "datastore")); err != nil {
+		t.Errorf("old datastore not restored: %s", err)
+	}
+
+	for _, p := range []string{oldDir, newDir, path.Join(dir, "blocks"), path.Join(dir, revert.ConvertLog)} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed, got %v", p, err)
+		}
+	}
+}
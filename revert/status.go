@@ -0,0 +1,131 @@
+package revert
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ipfs/ipfs-ds-convert/repo"
+
+	lock "github.com/ipfs/go-fs-lock"
+)
+
+// State classifies conversion state of a repo based on its convertlog
+type State string
+
+const (
+	// StateClean means there is no convertlog, no conversion was run or the
+	// last one finished without --keep, or was reverted or cleaned up
+	StateClean = State("clean")
+
+	// StateRunning means convertlog exists and the repo is locked, likely by
+	// a running conversion
+	StateRunning = State("running")
+
+	// StateFailedCopy means conversion stopped while copying keys, it can be
+	// resumed or reverted
+	StateFailedCopy = State("failed-mid-copy")
+
+	// StateFailed means conversion stopped after the copy phase or before
+	// anything was copied, it can only be reverted
+	StateFailed = State("failed")
+
+	// StateDoneWithBackup means conversion finished with --keep, backups can
+	// be removed with cleanup or the conversion reverted
+	StateDoneWithBackup = State("done-with-backup")
+
+	// StateManual means conversion stopped after steps which revert can't undo
+	// were done, the repo needs to be fixed by hand before running revert with
+	// --ack-manual
+	StateManual = State("manual-intervention-needed")
+)
+
+// Status describes conversion state of a repo
+type Status struct {
+	State State
+
+	// Steps is the number of steps recorded in convertlog
+	Steps int
+
+	// Checkpoint is the copy progress when State is StateFailedCopy
+	Checkpoint *Checkpoint
+
+	// Manual lists steps which must be undone by hand, in the order revert
+	// reaches them
+	Manual []ManualStep
+
+	// Leftovers are temp datastore dirs and spec backups found in the repo
+	Leftovers []string
+
+	// Next is the suggested command to run, empty when nothing needs to be
+	// done
+	Next string
+}
+
+// GetStatus inspects convertlog and leftover files in repo and classifies the
+// state of the last conversion
+func GetStatus(repoPath string) (*Status, error) {
+	leftovers, err := findLeftovers(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Status{
+		Leftovers: leftovers,
+	}
+
+	steps, err := loadLog(repoPath)
+	if os.IsNotExist(err) {
+		s.State = StateClean
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.Steps = len(steps)
+
+	s.Manual = manualSteps(repoPath, steps)
+
+	locked, err := lock.Locked(repoPath, repo.LockFile)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case locked:
+		s.State = StateRunning
+	case steps.top().action == ActionDone:
+		s.State = StateDoneWithBackup
+		s.Next = "ipfs-ds-convert cleanup"
+	case len(s.Manual) > 0:
+		s.State = StateManual
+		s.Next = "ipfs-ds-convert revert --ack-manual"
+	default:
+		s.Checkpoint, err = LoadCheckpoint(repoPath)
+		if err == nil {
+			s.State = StateFailedCopy
+			s.Next = "ipfs-ds-convert convert --resume"
+		} else {
+			s.State = StateFailed
+			s.Next = "ipfs-ds-convert revert"
+		}
+	}
+
+	return s, nil
+}
+
+// findLeftovers lists temp datastore directories, spec and config backups
+// conversion leaves in the repo
+func findLeftovers(repoPath string) ([]string, error) {
+	var out []string
+	for _, pattern := range []string{"ds-convert*", "datastore_spec_backup*", repo.ConfigFile + "_backup*", ConvertLog + ".tmp*"} {
+		matches, err := filepath.Glob(filepath.Join(repoPath, pattern))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, matches...)
+	}
+
+	sort.Strings(out)
+	return out, nil
+}
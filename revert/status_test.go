@@ -0,0 +1,102 @@
+package revert
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestGetStatus(t *testing.T) {
+	dname, _ := ioutil.TempDir(os.TempDir(), "ds-convert-test-")
+	defer os.RemoveAll(dname)
+
+	s, err := GetStatus(dname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.State != StateClean || s.Next != "" || len(s.Leftovers) != 0 {
+		t.Errorf("unexpected status %+v", s)
+	}
+
+	tmpDir := path.Join(dname, "ds-convert123")
+	if err := os.Mkdir(tmpDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		log   string
+		state State
+		next  string
+	}{
+		{
+			log: `{"action":"rm","arg":["` + tmpDir + `"]}
+{"action":"checkpoint","arg":["` + tmpDir + `","/a","1024"]}
+`,
+			state: StateFailedCopy,
+			next:  "ipfs-ds-convert convert --resume",
+		},
+		{
+			log: `{"action":"rm","arg":["` + tmpDir + `"]}
+{"action":"mv","arg":["/a","/b"]}
+`,
+			state: StateFailed,
+			next:  "ipfs-ds-convert revert",
+		},
+		{
+			log: `{"action":"rm","arg":["` + tmpDir + `"]}
+{"action":"manual","arg":["no backup data present for revert"]}
+`,
+			state: StateManual,
+			next:  "ipfs-ds-convert revert --ack-manual",
+		},
+		{
+			log: `{"action":"rm","arg":["` + tmpDir + `"]}
+{"action":"cleanup","arg":["` + tmpDir + `"]}
+{"action":"done","arg":[]}
+`,
+			state: StateDoneWithBackup,
+			next:  "ipfs-ds-convert cleanup",
+		},
+	}
+
+	for _, c := range cases {
+		_ = ioutil.WriteFile(path.Join(dname, ConvertLog), []byte(c.log), 0600)
+
+		s, err := GetStatus(dname)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if s.State != c.state || s.Next != c.next {
+			t.Errorf("unexpected status %+v, expected state %s, next '%s'", s, c.state, c.next)
+		}
+
+		if len(s.Leftovers) != 1 || s.Leftovers[0] != tmpDir {
+			t.Errorf("unexpected leftovers %v", s.Leftovers)
+		}
+	}
+
+	_ = ioutil.WriteFile(path.Join(dname, ConvertLog), []byte(cases[2].log), 0600)
+	s, err = GetStatus(dname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.Manual) != 1 || s.Manual[0].Message != "no backup data present for revert" {
+		t.Errorf("unexpected manual steps %v", s.Manual)
+	}
+
+	//config backups written by convert and config set-spec
+	configBackup := path.Join(dname, "config_backup123")
+	_ = ioutil.WriteFile(configBackup, []byte("{}"), 0600)
+	s, err = GetStatus(dname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.Leftovers) != 2 || s.Leftovers[0] != configBackup || s.Leftovers[1] != tmpDir {
+		t.Errorf("unexpected leftovers %v", s.Leftovers)
+	}
+}
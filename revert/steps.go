@@ -1,12 +1,13 @@
 package revert
 
 import (
-	"bufio"
-	"encoding/json"
+	"bytes"
 	"fmt"
-	"io"
 	"os"
 	"path"
+	"strconv"
+
+	"github.com/ipfs/ipfs-ds-convert/config"
 )
 
 type Step struct {
@@ -16,64 +17,59 @@ type Step struct {
 
 type Steps []Step
 
-func loadLog(repo string) (Steps, error) {
-	var steps []Step
+// Checkpoint describes progress of interrupted copy phase
+type Checkpoint struct {
+	//Dir is the temporary datastore directory keys are copied into
+	Dir string
+	//Key is the last key in committed batch, empty when nothing was committed
+	Key string
+	//Count is the number of keys copied so far
+	Count int
+	//Backups are files restored on revert which were logged before copying
+	Backups []string
+	//Strategy is the id of conversion strategy, empty for logs written by
+	//older versions
+	Strategy string
+}
 
-	f, err := os.Open(path.Join(repo, ConvertLog))
+// LoadCheckpoint reads convertlog in repo and returns last recorded copy
+// checkpoint. Conversions which went past the copy phase can't be resumed.
+func LoadCheckpoint(repo string) (*Checkpoint, error) {
+	header, steps, err := readLog(repo)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
-
-	log := bufio.NewReader(f)
-	var readErr error
-	for {
-		var line string
-		line, readErr = log.ReadString('\n')
-
-		if line == "" {
-			break
-		}
-
-		stepJson := map[string]interface{}{}
-		err := json.Unmarshal([]byte(line), &stepJson)
-		if err != nil {
-			return nil, err
-		}
-
-		action, ok := stepJson["action"].(string)
-		if !ok {
-			return nil, fmt.Errorf("invalid action type in convert steps: %s", line)
-		}
 
-		rawArgs, ok := stepJson["arg"].([]interface{})
-		var args []string
-		if ok {
-			args = make([]string, 0, len(rawArgs))
-			for i := range rawArgs {
-				arg, ok := rawArgs[i].(string)
-				if !ok {
-					return nil, fmt.Errorf("invalid arg %d in convert steps: %s", i, line)
-				}
-				args = append(args, arg)
+	var cp *Checkpoint
+	var backups []string
+	for _, step := range steps {
+		switch {
+		case step.action == ActionRestore && cp == nil && len(step.arg) == 2:
+			backups = append(backups, step.arg[0])
+		case step.action == ActionRemove && cp == nil && len(step.arg) == 1:
+			cp = &Checkpoint{Dir: step.arg[0]}
+		case step.action == ActionCheckpoint && cp != nil && len(step.arg) == 3 && step.arg[0] == cp.Dir:
+			count, err := strconv.Atoi(step.arg[2])
+			if err != nil {
+				return nil, fmt.Errorf("invalid checkpoint count: %s", err)
 			}
-		}
-
-		steps = append(steps, Step{
-			action: Action(action),
-			arg:    args,
-		})
 
-		if readErr != nil {
-			break
+			cp.Key = step.arg[1]
+			cp.Count = count
+		default:
+			return nil, fmt.Errorf("conversion can't be resumed from '%s' step, run revert", step.action)
 		}
 	}
 
-	if readErr != io.EOF {
-		return nil, readErr
+	if cp == nil {
+		return nil, fmt.Errorf("no copy progress in %s, run revert", ConvertLog)
 	}
 
-	return steps, nil
+	cp.Backups = backups
+	if header != nil {
+		cp.Strategy = header.Strategy
+	}
+	return cp, nil
 }
 
 func (s *Steps) top() Step {
@@ -92,32 +88,60 @@ func (s *Steps) pop(repo string) error {
 	return s.write(repo)
 }
 
+// write replaces convertlog with s atomically, so that after a crash
+// convertlog holds either the old or the new steps
 func (s *Steps) write(repo string) error {
+	logPath := path.Join(repo, ConvertLog)
+
 	if len(*s) == 0 {
-		return os.Remove(path.Join(repo, ConvertLog))
+		err := crashHook("remove")
+		if err != nil {
+			return err
+		}
+
+		err = os.Remove(logPath)
+		if err != nil {
+			return err
+		}
+
+		return config.SyncDir(repo)
 	}
 
-	f, err := os.Create(path.Join(repo, ConvertLog))
+	//keep header of the log, logs written by older versions get a new one
+	header, err := readHeader(repo)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if header == nil {
+		header = newHeader(repo, "")
+	}
+
+	h, err := header.Line()
 	if err != nil {
 		return err
 	}
-	defer f.Close()
 
+	var buf bytes.Buffer
+	buf.Write(h)
 	for _, step := range *s {
 		d, err := step.action.Line(step.arg...)
 		if err != nil {
 			return err
 		}
 
-		n, err := f.Write(d)
-		if err != nil {
-			return err
-		}
+		buf.Write(d)
+	}
 
-		if n != len(d) {
-			return fmt.Errorf("failed to write steps, wrote %d, expected %d", n, len(d))
-		}
+	err = crashHook("write")
+	if err != nil {
+		return err
 	}
 
+	return config.WriteFileAtomic(logPath, buf.Bytes(), 0600)
+}
+
+// crashHook is called before convertlog is replaced or removed during revert,
+// tests use it to simulate crashes
+var crashHook = func(op string) error {
 	return nil
 }
@@ -1,6 +1,7 @@
 package revert
 
 import (
+	"errors"
 	"io/ioutil"
 	"os"
 	"path"
@@ -40,3 +41,152 @@ func TestLoadInvalidLog(t *testing.T) {
 
 	os.Remove(dname)
 }
+
+func TestLoadCheckpoint(t *testing.T) {
+	dname, _ := ioutil.TempDir(os.TempDir(), "ds-convert-test-")
+	defer os.RemoveAll(dname)
+
+	_ = ioutil.WriteFile(path.Join(dname, ConvertLog), []byte(`{"action":"rm","arg":["/tmp/ds-convert1"]}
+{"action":"checkpoint","arg":["/tmp/ds-convert1","/a","1024"]}
+{"action":"checkpoint","arg":["/tmp/ds-convert1","/b","2048"]}
+`), 0600)
+
+	cp, err := LoadCheckpoint(dname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cp.Dir != "/tmp/ds-convert1" || cp.Key != "/b" || cp.Count != 2048 {
+		t.Errorf("unexpected checkpoint %v", cp)
+	}
+
+	_ = ioutil.WriteFile(path.Join(dname, ConvertLog), []byte(`{"action":"restore","arg":["/tmp/config_backup1","/tmp/config"]}
+{"action":"rm","arg":["/tmp/ds-convert1"]}
+{"action":"checkpoint","arg":["/tmp/ds-convert1","/a","1024"]}
+`), 0600)
+
+	cp, err = LoadCheckpoint(dname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cp.Count != 1024 || len(cp.Backups) != 1 || cp.Backups[0] != "/tmp/config_backup1" {
+		t.Errorf("unexpected checkpoint %v", cp)
+	}
+
+	_ = ioutil.WriteFile(path.Join(dname, ConvertLog), []byte(`{"action":"rm","arg":["/tmp/ds-convert1"]}
+{"action":"checkpoint","arg":["/tmp/ds-convert1","/a","1024"]}
+{"action":"rm","arg":["/tmp/ds-convert-old1"]}
+`), 0600)
+
+	_, err = LoadCheckpoint(dname)
+	if err == nil || !strings.Contains(err.Error(), "conversion can't be resumed from 'rm' step, run revert") {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	_ = ioutil.WriteFile(path.Join(dname, ConvertLog), []byte{}, 0600)
+
+	_, err = LoadCheckpoint(dname)
+	if err == nil || !strings.Contains(err.Error(), "no copy progress in convertlog, run revert") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestLoadTruncatedLog(t *testing.T) {
+	dname, _ := ioutil.TempDir(os.TempDir(), "ds-convert-test-")
+	defer os.RemoveAll(dname)
+
+	_ = ioutil.WriteFile(path.Join(dname, ConvertLog), []byte(`{"action":"rm","arg":["/a"]}
+{"action":"mv","arg":["/b",`), 0600)
+
+	steps, err := loadLog(dname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(steps) != 1 || steps[0].action != ActionRemove {
+		t.Errorf("unexpected steps %v", steps)
+	}
+}
+
+func TestPopCrash(t *testing.T) {
+	dname, _ := ioutil.TempDir(os.TempDir(), "ds-convert-test-")
+	defer os.RemoveAll(dname)
+
+	steps := Steps{
+		{action: ActionRemove, arg: []string{"/a"}},
+		{action: ActionMove, arg: []string{"/b", "/c"}},
+		{action: ActionCleanup, arg: []string{"/d"}},
+	}
+
+	noCrash := crashHook
+	defer func() {
+		crashHook = noCrash
+	}()
+
+	crashed := errors.New("simulated crash")
+
+	//crash at every filesystem operation while popping all steps, log must
+	//always hold either the state before or after the interrupted pop
+	for n := 0; ; n++ {
+		crashHook = noCrash
+		if err := steps.write(dname); err != nil {
+			t.Fatal(err)
+		}
+
+		calls := 0
+		crashHook = func(op string) error {
+			calls++
+			if calls == n+1 {
+				return crashed
+			}
+			return nil
+		}
+
+		cur := append(Steps{}, steps...)
+		var err error
+		for len(cur) > 0 && err == nil {
+			err = cur.pop(dname)
+		}
+		crashHook = noCrash
+
+		if err == nil {
+			if n == 0 {
+				t.Fatal("crash hook was never called")
+			}
+			break
+		}
+		if err != crashed {
+			t.Fatal(err)
+		}
+
+		loaded, err := loadLog(dname)
+		if os.IsNotExist(err) {
+			if len(cur) != 0 {
+				t.Fatalf("crash %d: log removed with %d steps left", n, len(cur))
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("crash %d: %s", n, err)
+		}
+
+		if len(loaded) != len(cur) && len(loaded) != len(cur)+1 {
+			t.Fatalf("crash %d: log has %d steps, expected %d or %d", n, len(loaded), len(cur), len(cur)+1)
+		}
+
+		for i := range loaded {
+			if loaded[i].action != steps[i].action {
+				t.Fatalf("crash %d: unexpected step %d: %v", n, i, loaded[i])
+			}
+		}
+
+		files, err := ioutil.ReadDir(dname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) != 1 {
+			t.Fatalf("crash %d: unexpected files in repo: %d", n, len(files))
+		}
+	}
+}
@@ -88,3 +88,157 @@ func (s *noopStrategy) Id() string {
 	b, _ := json.Marshal(s.Spec())
 	return string(b)
 }
+
+// Move describes renaming of a datastore directory inside the repo
+type Move struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type moveStrategy struct {
+	moves []Move
+}
+
+func NewMoveStrategy(moves []Move) (Strategy, error) {
+	if len(moves) == 0 {
+		return nil, errors.New("move strategy has no moves")
+	}
+
+	for _, m := range moves {
+		if m.From == "" || m.To == "" || m.From == m.To {
+			return nil, errors.Errorf("invalid move '%s' -> '%s'", m.From, m.To)
+		}
+	}
+
+	return &moveStrategy{
+		moves: moves,
+	}, nil
+}
+
+func (s *moveStrategy) Spec() Spec {
+	return Spec{
+		"type":  "move",
+		"moves": s.moves,
+	}
+}
+
+func (s *moveStrategy) Id() string {
+	b, _ := json.Marshal(s.Spec())
+	return string(b)
+}
+
+// Rename describes a mount which keeps its datastore directory, but is mounted
+// under a different prefix
+type Rename struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Path string `json:"path"`
+}
+
+type renameStrategy struct {
+	renames []Rename
+}
+
+func NewRenameStrategy(renames []Rename) (Strategy, error) {
+	if len(renames) == 0 {
+		return nil, errors.New("rename strategy has no renames")
+	}
+
+	for _, r := range renames {
+		if r.From == "" || r.To == "" || r.From == r.To {
+			return nil, errors.Errorf("invalid rename '%s' -> '%s'", r.From, r.To)
+		}
+	}
+
+	return &renameStrategy{
+		renames: renames,
+	}, nil
+}
+
+func (s *renameStrategy) Spec() Spec {
+	return Spec{
+		"type":    "rename",
+		"renames": s.renames,
+	}
+}
+
+func (s *renameStrategy) Id() string {
+	b, _ := json.Marshal(s.Spec())
+	return string(b)
+}
+
+// Reshard describes moving files of a flatfs datastore between shard
+// directories, when only the shard function changes
+type Reshard struct {
+	Path string `json:"path"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type reshardStrategy struct {
+	reshards []Reshard
+}
+
+func NewReshardStrategy(reshards []Reshard) (Strategy, error) {
+	if len(reshards) == 0 {
+		return nil, errors.New("reshard strategy has no reshards")
+	}
+
+	for _, r := range reshards {
+		if r.Path == "" || r.From == "" || r.To == "" || r.From == r.To {
+			return nil, errors.Errorf("invalid reshard of '%s' '%s' -> '%s'", r.Path, r.From, r.To)
+		}
+	}
+
+	return &reshardStrategy{
+		reshards: reshards,
+	}, nil
+}
+
+func (s *reshardStrategy) Spec() Spec {
+	return Spec{
+		"type":     "reshard",
+		"reshards": s.reshards,
+	}
+}
+
+func (s *reshardStrategy) Id() string {
+	b, _ := json.Marshal(s.Spec())
+	return string(b)
+}
+
+type multiStrategy struct {
+	steps []Strategy
+}
+
+// NewMultiStrategy combines strategies which are executed in order. Returns
+// the only step directly when there is one, and noop when there are none
+func NewMultiStrategy(steps ...Strategy) (Strategy, error) {
+	switch len(steps) {
+	case 0:
+		return NewNoopStrategy()
+	case 1:
+		return steps[0], nil
+	}
+
+	return &multiStrategy{
+		steps: steps,
+	}, nil
+}
+
+func (s *multiStrategy) Spec() Spec {
+	steps := make([]Spec, 0, len(s.steps))
+	for _, step := range s.steps {
+		steps = append(steps, step.Spec())
+	}
+
+	return Spec{
+		"type":  "multi",
+		"steps": steps,
+	}
+}
+
+func (s *multiStrategy) Id() string {
+	b, _ := json.Marshal(s.Spec())
+	return string(b)
+}
@@ -17,19 +17,33 @@ var skipTypes = map[string]string{
 }
 
 var dsTypes = map[string]bool{
-	"flatfs":   true,
-	"levelds":  true,
-	"badgerds": true,
+	"flatfs":    true,
+	"levelds":   true,
+	"badgerds":  true,
+	"badger2ds": true,
 }
 
 //datastors that have one directory inside IPFS repo
 var simpleTypes = map[string]bool{
-	"flatfs":   true,
-	"levelds":  true,
-	"badgerds": true,
+	"flatfs":    true,
+	"levelds":   true,
+	"badgerds":  true,
+	"badger2ds": true,
+}
+
+// Options enable optional conversion shortcuts
+type Options struct {
+	// RenameMounts keeps data of mounts whose mountpoint changed, but disk
+	// spec and directory didn't, in place. Keys of such mounts change their
+	// prefix instead of being copied under the old one
+	RenameMounts bool
 }
 
 func NewStrategy(fromSpecIn, toSpecIn map[string]interface{}) (Strategy, error) {
+	return NewStrategyWithOptions(fromSpecIn, toSpecIn, Options{})
+}
+
+func NewStrategyWithOptions(fromSpecIn, toSpecIn map[string]interface{}, opts Options) (Strategy, error) {
 	var fromSpec Spec
 	var toSpec Spec
 
@@ -48,7 +62,31 @@ func NewStrategy(fromSpecIn, toSpecIn map[string]interface{}) (Strategy, error)
 
 	if _, ok := dsTypes[fromType]; ok {
 		if toType == fromType {
-			//TODO: check if dirs match, can just skip conversion, else just move directories
+			same, err := sameExceptPath(fromSpec, toSpec)
+			if err != nil {
+				return nil, err
+			}
+
+			if same {
+				fromPath, _ := fromSpec.str("path")
+				toPath, _ := toSpec.str("path")
+
+				if fromPath == toPath {
+					return NewNoopStrategy()
+				}
+
+				return NewMoveStrategy([]Move{{From: fromPath, To: toPath}})
+			}
+
+			reshard, err := reshardOf(fromSpec, toSpec)
+			if err != nil {
+				return nil, err
+			}
+
+			if reshard != nil {
+				return NewReshardStrategy([]Reshard{*reshard})
+			}
+
 			return NewCopyStrategy(fromSpec, toSpec)
 		}
 
@@ -63,7 +101,7 @@ func NewStrategy(fromSpecIn, toSpecIn map[string]interface{}) (Strategy, error)
 			return NewCopyStrategy(fromSpec, toSpec)
 		}
 
-		return newMountStrategy(fromSpec, toSpec)
+		return newMountStrategy(fromSpec, toSpec, opts)
 	}
 
 	//should not normally happen
@@ -221,7 +259,133 @@ func addMissingParents(specA SimpleMounts, specB SimpleMounts, specAOpt SimpleMo
 	return specAOpt, specBOpt, nil
 }
 
-func newMountStrategy(fromSpec, toSpec map[string]interface{}) (Strategy, error) {
+// mountPair is a mount from the old spec and its counterpart in the new spec
+type mountPair struct {
+	from SimpleMount
+	to   SimpleMount
+}
+
+// findMoves finds mounts which keep their prefix and disk format, but whose
+// directory changes. Moves which would collide with a directory used by the
+// other spec are not considered
+func findMoves(fromMounts, toMounts, skipable SimpleMounts) ([]mountPair, error) {
+	var moves []mountPair
+
+	for _, from := range fromMounts {
+		if skipable.hasMatching(from) {
+			continue
+		}
+
+		i := toMounts.hasPrefixed(from)
+		if i == -1 {
+			continue
+		}
+		to := toMounts[i]
+
+		same, err := sameExceptPath(from.spec, to.spec)
+		if err != nil {
+			return nil, err
+		}
+		if !same {
+			continue
+		}
+
+		fromPath, _ := from.spec.str("path")
+		toPath, _ := to.spec.str("path")
+		if fromMounts.hasPath(toPath) || toMounts.hasPath(fromPath) {
+			continue
+		}
+
+		moves = append(moves, mountPair{from: from, to: to})
+	}
+
+	return moves, nil
+}
+
+// findReshards finds flatfs mounts which keep their prefix and directory, but
+// change the shard function
+func findReshards(fromMounts, toMounts, skipable SimpleMounts) ([]mountPair, error) {
+	var reshards []mountPair
+
+	for _, from := range fromMounts {
+		if skipable.hasMatching(from) {
+			continue
+		}
+
+		i := toMounts.hasPrefixed(from)
+		if i == -1 {
+			continue
+		}
+		to := toMounts[i]
+
+		reshard, err := reshardOf(from.spec, to.spec)
+		if err != nil {
+			return nil, err
+		}
+
+		if reshard != nil {
+			reshards = append(reshards, mountPair{from: from, to: to})
+		}
+	}
+
+	return reshards, nil
+}
+
+// keepOutOfCopy returns pairs which aren't copied. Mounts of pairs which
+// were added to the copy specs as parents of copied mounts are copied whole
+func keepOutOfCopy(pairs []mountPair, fromOpt, toOpt SimpleMounts) ([]mountPair, SimpleMounts, SimpleMounts) {
+	var kept []mountPair
+
+	for _, p := range pairs {
+		if fromOpt.hasPrefixed(p.from) != -1 || toOpt.hasPrefixed(p.to) != -1 {
+			if fromOpt.hasPrefixed(p.from) == -1 {
+				fromOpt = append(fromOpt, p.from)
+			}
+			if toOpt.hasPrefixed(p.to) == -1 {
+				toOpt = append(toOpt, p.to)
+			}
+			continue
+		}
+
+		kept = append(kept, p)
+	}
+
+	return kept, fromOpt, toOpt
+}
+
+// findRenames finds mounts which keep their directory and disk format, but are
+// mounted under a new prefix. Only mounts with no other mounts at or below
+// the old and new prefix in either spec are considered
+func findRenames(fromMounts, toMounts, skipable SimpleMounts) []mountPair {
+	var renames []mountPair
+
+	for _, from := range fromMounts {
+		if skipable.hasMatching(from) || toMounts.hasPrefixed(from) != -1 {
+			continue
+		}
+
+		for _, to := range toMounts {
+			if to.diskId != from.diskId || fromMounts.hasPrefixed(to) != -1 {
+				continue
+			}
+
+			if from.prefix.IsAncestorOf(to.prefix) || to.prefix.IsAncestorOf(from.prefix) {
+				continue
+			}
+
+			if fromMounts.hasUnder(from.prefix, from) || fromMounts.hasUnder(to.prefix) ||
+				toMounts.hasUnder(to.prefix, to) || toMounts.hasUnder(from.prefix) {
+				continue
+			}
+
+			renames = append(renames, mountPair{from: from, to: to})
+		}
+	}
+
+	return renames
+}
+
+func newMountStrategy(fromSpec, toSpec map[string]interface{}, opts Options) (Strategy, error) {
 	var skipable []SimpleMount
 
 	fromMounts, err := simpleMountInfo(fromSpec)
@@ -240,10 +404,39 @@ func newMountStrategy(fromSpec, toSpec map[string]interface{}) (Strategy, error)
 		}
 	}
 
-	//TODO: handle renames, somehow
+	var renames []mountPair
+	if opts.RenameMounts {
+		renames = findRenames(fromMounts, toMounts, skipable)
+	}
+
+	moves, err := findMoves(fromMounts, toMounts, skipable)
+	if err != nil {
+		return nil, err
+	}
+
+	reshards, err := findReshards(fromMounts, toMounts, skipable)
+	if err != nil {
+		return nil, err
+	}
+
+	var renamedFrom, renamedTo, movedFrom, movedTo SimpleMounts
+	for _, r := range renames {
+		renamedFrom = append(renamedFrom, r.from)
+		renamedTo = append(renamedTo, r.to)
+	}
+	for _, m := range append(moves, reshards...) {
+		movedFrom = append(movedFrom, m.from)
+		movedTo = append(movedTo, m.to)
+	}
+
+	//renamed mounts don't take part in copying, so they can't be parents
+	fromMounts = fromMounts.filter(renamedFrom)
+	toMounts = toMounts.filter(renamedTo)
 
 	fromMountsOpt := fromMounts.filter(skipable)
+	fromMountsOpt = fromMountsOpt.filter(movedFrom)
 	toMountsOpt := toMounts.filter(skipable)
+	toMountsOpt = toMountsOpt.filter(movedTo)
 
 	fromMountsOpt.sort()
 	toMountsOpt.sort()
@@ -258,18 +451,74 @@ func newMountStrategy(fromSpec, toSpec map[string]interface{}) (Strategy, error)
 		return nil, errors.Wrapf(err, "adding missing to dest spec")
 	}
 
-	if len(fromMountsOpt) == 0 {
-		if len(toMountsOpt) != 0 {
-			return nil, fmt.Errorf("strategy error: len(toMounts) != 0, please report")
+	//moved and resharded mounts may have been added back as parents of copied
+	//mounts
+	moves, fromMountsOpt, toMountsOpt = keepOutOfCopy(moves, fromMountsOpt, toMountsOpt)
+	reshards, fromMountsOpt, toMountsOpt = keepOutOfCopy(reshards, fromMountsOpt, toMountsOpt)
+
+	var steps []Strategy
+
+	if len(renames) != 0 {
+		var mountRenames []Rename
+		for _, r := range renames {
+			path, _ := r.from.spec.str("path")
+			mountRenames = append(mountRenames, Rename{From: r.from.prefix.String(), To: r.to.prefix.String(), Path: path})
 		}
 
-		return NewNoopStrategy()
+		s, err := NewRenameStrategy(mountRenames)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, s)
 	}
-	if len(toMountsOpt) == 0 {
-		return nil, fmt.Errorf("strategy error: len(toMounts) == 0, please report")
+
+	if len(fromMountsOpt) != 0 {
+		if len(toMountsOpt) == 0 {
+			return nil, fmt.Errorf("strategy error: len(toMounts) == 0, please report")
+		}
+
+		s, err := NewCopyStrategy(fromMountsOpt.spec(), toMountsOpt.spec())
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, s)
+	} else if len(toMountsOpt) != 0 {
+		return nil, fmt.Errorf("strategy error: len(toMounts) != 0, please report")
+	}
+
+	if len(moves) != 0 {
+		var dirMoves []Move
+		for _, m := range moves {
+			fromPath, _ := m.from.spec.str("path")
+			toPath, _ := m.to.spec.str("path")
+			dirMoves = append(dirMoves, Move{From: fromPath, To: toPath})
+		}
+
+		s, err := NewMoveStrategy(dirMoves)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, s)
+	}
+
+	if len(reshards) != 0 {
+		var flatfsReshards []Reshard
+		for _, r := range reshards {
+			reshard, err := reshardOf(r.from.spec, r.to.spec)
+			if err != nil {
+				return nil, err
+			}
+			flatfsReshards = append(flatfsReshards, *reshard)
+		}
+
+		s, err := NewReshardStrategy(flatfsReshards)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, s)
 	}
 
-	return NewCopyStrategy(fromMountsOpt.spec(), toMountsOpt.spec())
+	return NewMultiStrategy(steps...)
 }
 
 func matchKeyPartsPrefix(pattern, to []string) int {
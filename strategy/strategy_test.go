@@ -10,6 +10,7 @@ import (
 type testCase struct {
 	baseSpec map[string]interface{}
 	destSpec map[string]interface{}
+	opts     strategy.Options
 	strategy string
 	err      string
 }
@@ -121,6 +122,27 @@ var (
 			},
 			strategy: `{"from":{"mounts":[{"mountpoint":"/blocks","path":"blocks","shardFunc":"/repo/flatfs/shard/v1/next-to-last/2","sync":true,"type":"flatfs"}],"type":"mount"},"to":{"mounts":[{"mountpoint":"/blocks","path":"blocks","type":"badgerds"}],"type":"mount"},"type":"copy"}`,
 		},
+		{
+			//badger major versions aren't compatible on disk, needs to copy
+			baseSpec: map[string]interface{}{
+				"type":   "measure",
+				"prefix": "badger.datastore",
+				"child": map[string]interface{}{
+					"type": "badgerds",
+					"path": "badgerds",
+				},
+			},
+			destSpec: map[string]interface{}{
+				"type":   "measure",
+				"prefix": "badger.datastore",
+				"child": map[string]interface{}{
+					"type":         "badger2ds",
+					"path":         "badger2ds",
+					"vlogFileSize": "1GiB",
+				},
+			},
+			strategy: `{"from":{"path":"badgerds","type":"badgerds"},"to":{"path":"badger2ds","type":"badger2ds","vlogFileSize":"1GiB"},"type":"copy"}`,
+		},
 		{
 			//adds /foo mount, needs to copy [/,/foo]
 			baseSpec: basicSpec,
@@ -281,6 +303,297 @@ var (
 			},
 			err: "parsing old spec: mount entry is not simple, mount datastores can't be nested",
 		},
+		{
+			//only path of single datastore changed, directory can be moved
+			baseSpec: map[string]interface{}{
+				"type":        "levelds",
+				"path":        "levelDatastore",
+				"compression": "none",
+			},
+			destSpec: map[string]interface{}{
+				"type":   "measure",
+				"prefix": "leveldb.datastore",
+				"child": map[string]interface{}{
+					"type":        "levelds",
+					"path":        "otherDatastore",
+					"compression": "none",
+				},
+			},
+			strategy: `{"moves":[{"from":"levelDatastore","to":"otherDatastore"}],"type":"move"}`,
+		},
+		{
+			//single datastore with the same disk spec
+			baseSpec: map[string]interface{}{
+				"type":        "levelds",
+				"path":        "levelDatastore",
+				"compression": "none",
+			},
+			destSpec: map[string]interface{}{
+				"type":        "levelds",
+				"path":        "levelDatastore",
+				"compression": "snappy",
+			},
+			strategy: `{"type":"noop"}`,
+		},
+		{
+			//shard function changed, needs copy
+			baseSpec: map[string]interface{}{
+				"type":      "flatfs",
+				"path":      "blocks",
+				"sync":      true,
+				"shardFunc": "/repo/flatfs/shard/v1/next-to-last/2",
+			},
+			destSpec: map[string]interface{}{
+				"type":      "flatfs",
+				"path":      "blocks2",
+				"sync":      true,
+				"shardFunc": "/repo/flatfs/shard/v1/prefix/4",
+			},
+			strategy: `{"from":{"path":"blocks","shardFunc":"/repo/flatfs/shard/v1/next-to-last/2","sync":true,"type":"flatfs"},"to":{"path":"blocks2","shardFunc":"/repo/flatfs/shard/v1/prefix/4","sync":true,"type":"flatfs"},"type":"copy"}`,
+		},
+		{
+			//blocks moved to another directory, only the directory is moved
+			baseSpec: basicSpec,
+			destSpec: map[string]interface{}{
+				"type": "mount",
+				"mounts": []interface{}{
+					map[string]interface{}{
+						"mountpoint": "/blocks",
+						"type":       "flatfs",
+						"path":       "blocks2",
+						"sync":       true,
+						"shardFunc":  "/repo/flatfs/shard/v1/next-to-last/2",
+					},
+					map[string]interface{}{
+						"mountpoint":  "/",
+						"type":        "levelds",
+						"path":        "levelDatastore",
+						"compression": "none",
+					},
+				},
+			},
+			strategy: `{"moves":[{"from":"blocks","to":"blocks2"}],"type":"move"}`,
+		},
+		{
+			//directories of two mounts swapped, needs copy
+			baseSpec: map[string]interface{}{
+				"type": "mount",
+				"mounts": []interface{}{
+					map[string]interface{}{
+						"mountpoint": "/a",
+						"type":       "badgerds",
+						"path":       "dsa",
+					},
+					map[string]interface{}{
+						"mountpoint": "/",
+						"type":       "badgerds",
+						"path":       "ds",
+					},
+				},
+			},
+			destSpec: map[string]interface{}{
+				"type": "mount",
+				"mounts": []interface{}{
+					map[string]interface{}{
+						"mountpoint": "/a",
+						"type":       "badgerds",
+						"path":       "ds",
+					},
+					map[string]interface{}{
+						"mountpoint": "/",
+						"type":       "badgerds",
+						"path":       "dsa",
+					},
+				},
+			},
+			strategy: `{"from":{"mounts":[{"mountpoint":"/a","path":"dsa","type":"badgerds"},{"mountpoint":"/","path":"ds","type":"badgerds"}],"type":"mount"},"to":{"mounts":[{"mountpoint":"/a","path":"ds","type":"badgerds"},{"mountpoint":"/","path":"dsa","type":"badgerds"}],"type":"mount"},"type":"copy"}`,
+		},
+		{
+			//moved root mount is a parent of removed /a, so it's copied
+			baseSpec: map[string]interface{}{
+				"type": "mount",
+				"mounts": []interface{}{
+					map[string]interface{}{
+						"mountpoint": "/a",
+						"type":       "badgerds",
+						"path":       "dsa",
+					},
+					map[string]interface{}{
+						"mountpoint": "/",
+						"type":       "badgerds",
+						"path":       "ds",
+					},
+				},
+			},
+			destSpec: map[string]interface{}{
+				"type": "mount",
+				"mounts": []interface{}{
+					map[string]interface{}{
+						"mountpoint": "/",
+						"type":       "badgerds",
+						"path":       "ds2",
+					},
+				},
+			},
+			strategy: `{"from":{"mounts":[{"mountpoint":"/a","path":"dsa","type":"badgerds"},{"mountpoint":"/","path":"ds","type":"badgerds"}],"type":"mount"},"to":{"mounts":[{"mountpoint":"/","path":"ds2","type":"badgerds"}],"type":"mount"},"type":"copy"}`,
+		},
+		{
+			//skippable spec with renames enabled, /c is renamed to /d
+			baseSpec: map[string]interface{}{
+				"type": "mount",
+				"mounts": []interface{}{
+					map[string]interface{}{
+						"mountpoint": "/a",
+						"type":       "badgerds",
+						"path":       "dsa",
+					},
+					map[string]interface{}{
+						"mountpoint": "/b",
+						"type":       "badgerds",
+						"path":       "dsb",
+					},
+					map[string]interface{}{
+						"mountpoint": "/c",
+						"type":       "badgerds",
+						"path":       "dsc",
+					},
+					map[string]interface{}{
+						"mountpoint": "/",
+						"type":       "badgerds",
+						"path":       "ds",
+					},
+				},
+			},
+			destSpec: map[string]interface{}{
+				"type": "mount",
+				"mounts": []interface{}{
+					map[string]interface{}{
+						"mountpoint": "/a",
+						"type":       "badgerds",
+						"path":       "dsa",
+					},
+					map[string]interface{}{
+						"mountpoint":  "/b",
+						"type":        "levelds",
+						"path":        "dsb",
+						"compression": "none",
+					},
+					map[string]interface{}{
+						"mountpoint": "/",
+						"type":       "badgerds",
+						"path":       "ds",
+					},
+					map[string]interface{}{
+						"mountpoint": "/d",
+						"type":       "badgerds",
+						"path":       "dsc",
+					},
+				},
+			},
+			opts:     strategy.Options{RenameMounts: true},
+			strategy: `{"steps":[{"renames":[{"from":"/c","to":"/d","path":"dsc"}],"type":"rename"},{"from":{"mounts":[{"mountpoint":"/b","path":"dsb","type":"badgerds"}],"type":"mount"},"to":{"mounts":[{"compression":"none","mountpoint":"/b","path":"dsb","type":"levelds"}],"type":"mount"},"type":"copy"}],"type":"multi"}`,
+		},
+		{
+			//renamed mount with a new mount below the new prefix isn't renamed
+			baseSpec: map[string]interface{}{
+				"type": "mount",
+				"mounts": []interface{}{
+					map[string]interface{}{
+						"mountpoint": "/c",
+						"type":       "badgerds",
+						"path":       "dsc",
+					},
+					map[string]interface{}{
+						"mountpoint": "/",
+						"type":       "badgerds",
+						"path":       "ds",
+					},
+				},
+			},
+			destSpec: map[string]interface{}{
+				"type": "mount",
+				"mounts": []interface{}{
+					map[string]interface{}{
+						"mountpoint": "/d",
+						"type":       "badgerds",
+						"path":       "dsc",
+					},
+					map[string]interface{}{
+						"mountpoint": "/d/e",
+						"type":       "badgerds",
+						"path":       "dse",
+					},
+					map[string]interface{}{
+						"mountpoint": "/",
+						"type":       "badgerds",
+						"path":       "ds",
+					},
+				},
+			},
+			opts:     strategy.Options{RenameMounts: true},
+			strategy: `{"from":{"mounts":[{"mountpoint":"/c","path":"dsc","type":"badgerds"},{"mountpoint":"/","path":"ds","type":"badgerds"}],"type":"mount"},"to":{"mounts":[{"mountpoint":"/d/e","path":"dse","type":"badgerds"},{"mountpoint":"/d","path":"dsc","type":"badgerds"},{"mountpoint":"/","path":"ds","type":"badgerds"}],"type":"mount"},"type":"copy"}`,
+		},
+		{
+			//only shard function of single flatfs changed, files are resharded
+			baseSpec: map[string]interface{}{
+				"type":      "flatfs",
+				"path":      "blocks",
+				"sync":      true,
+				"shardFunc": "/repo/flatfs/shard/v1/next-to-last/2",
+			},
+			destSpec: map[string]interface{}{
+				"type":      "flatfs",
+				"path":      "blocks",
+				"sync":      false,
+				"shardFunc": "/repo/flatfs/shard/v1/prefix/4",
+			},
+			strategy: `{"reshards":[{"path":"blocks","from":"/repo/flatfs/shard/v1/next-to-last/2","to":"/repo/flatfs/shard/v1/prefix/4"}],"type":"reshard"}`,
+		},
+		{
+			//shard function of /blocks changed, files are resharded in place
+			baseSpec: basicSpec,
+			destSpec: map[string]interface{}{
+				"type": "mount",
+				"mounts": []interface{}{
+					map[string]interface{}{
+						"mountpoint": "/blocks",
+						"type":       "flatfs",
+						"path":       "blocks",
+						"sync":       true,
+						"shardFunc":  "/repo/flatfs/shard/v1/prefix/4",
+					},
+					map[string]interface{}{
+						"mountpoint":  "/",
+						"type":        "levelds",
+						"path":        "levelDatastore",
+						"compression": "none",
+					},
+				},
+			},
+			strategy: `{"reshards":[{"path":"blocks","from":"/repo/flatfs/shard/v1/next-to-last/2","to":"/repo/flatfs/shard/v1/prefix/4"}],"type":"reshard"}`,
+		},
+		{
+			//shard function of /blocks changed and / is copied
+			baseSpec: basicSpec,
+			destSpec: map[string]interface{}{
+				"type": "mount",
+				"mounts": []interface{}{
+					map[string]interface{}{
+						"mountpoint": "/blocks",
+						"type":       "flatfs",
+						"path":       "blocks",
+						"sync":       true,
+						"shardFunc":  "/repo/flatfs/shard/v1/prefix/4",
+					},
+					map[string]interface{}{
+						"mountpoint": "/",
+						"type":       "badgerds",
+						"path":       "badgerDatastore",
+					},
+				},
+			},
+			strategy: `{"steps":[{"from":{"mounts":[{"compression":"none","mountpoint":"/","path":"levelDatastore","type":"levelds"}],"type":"mount"},"to":{"mounts":[{"mountpoint":"/","path":"badgerDatastore","type":"badgerds"}],"type":"mount"},"type":"copy"},{"reshards":[{"path":"blocks","from":"/repo/flatfs/shard/v1/next-to-last/2","to":"/repo/flatfs/shard/v1/prefix/4"}],"type":"reshard"}],"type":"multi"}`,
+		},
 		////////////////////
 		//EDGE CASES
 
@@ -403,7 +716,7 @@ var (
 
 func TestNewStrategy(t *testing.T) {
 	for _, c := range testCases {
-		strat, err := strategy.NewStrategy(c.baseSpec, c.destSpec)
+		strat, err := strategy.NewStrategyWithOptions(c.baseSpec, c.destSpec, c.opts)
 		assert(t, (err == nil && c.err == "") || (c.err != "" && strings.Contains(err.Error(), c.err)), err)
 		if c.err == "" {
 			assert(t, strat.Id() == c.strategy, strat.Id())
@@ -413,7 +726,7 @@ func TestNewStrategy(t *testing.T) {
 
 func TestStrategyReverse(t *testing.T) {
 	for _, c := range testCases {
-		_, err := strategy.NewStrategy(c.destSpec, c.baseSpec)
+		_, err := strategy.NewStrategyWithOptions(c.destSpec, c.baseSpec, c.opts)
 		assert(t, err == nil || c.err != "", err)
 	}
 }
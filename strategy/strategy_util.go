@@ -6,6 +6,7 @@ import (
 	"github.com/ipfs/ipfs-ds-convert/repo"
 
 	ds "github.com/ipfs/go-datastore"
+	errors "github.com/pkg/errors"
 )
 
 type Spec map[string]interface{}
@@ -36,6 +37,100 @@ func (s *Spec) Id() (string, error) {
 	return repo.DatastoreSpec(*s)
 }
 
+// Moves returns directory moves of a move strategy spec
+func (s *Spec) Moves() ([]Move, bool) {
+	t, ok := (*s)["moves"]
+	if !ok {
+		return nil, false
+	}
+	moves, ok := t.([]Move)
+	return moves, ok
+}
+
+// Renames returns mountpoint renames of a rename strategy spec
+func (s *Spec) Renames() ([]Rename, bool) {
+	t, ok := (*s)["renames"]
+	if !ok {
+		return nil, false
+	}
+	renames, ok := t.([]Rename)
+	return renames, ok
+}
+
+// Steps returns steps of a multi strategy spec
+func (s *Spec) Steps() ([]Spec, bool) {
+	t, ok := (*s)["steps"]
+	if !ok {
+		return nil, false
+	}
+	steps, ok := t.([]Spec)
+	return steps, ok
+}
+
+// Reshards returns flatfs reshards of a reshard strategy spec
+func (s *Spec) Reshards() ([]Reshard, bool) {
+	t, ok := (*s)["reshards"]
+	if !ok {
+		return nil, false
+	}
+	reshards, ok := t.([]Reshard)
+	return reshards, ok
+}
+
+// sameExcept checks if two simple datastore specs describe the same data
+// on disk, ignoring differences in field
+func sameExcept(a, b Spec, field string) (bool, error) {
+	c := Spec{}
+	for k, v := range a {
+		c[k] = v
+	}
+	c[field] = b[field]
+
+	aId, err := c.Id()
+	if err != nil {
+		return false, err
+	}
+
+	bId, err := b.Id()
+	if err != nil {
+		return false, err
+	}
+
+	return aId == bId, nil
+}
+
+// sameExceptPath checks if two simple datastore specs describe the same data
+// format on disk, possibly in different directories
+func sameExceptPath(a, b Spec) (bool, error) {
+	return sameExcept(a, b, "path")
+}
+
+// reshardOf returns a reshard turning flatfs spec a into b, or nil if they
+// differ in more than the shard function
+func reshardOf(a, b Spec) (*Reshard, error) {
+	aType, _ := a.Type()
+	bType, _ := b.Type()
+	if aType != "flatfs" || bType != "flatfs" {
+		return nil, nil
+	}
+
+	aPath, _ := a.str("path")
+	bPath, _ := b.str("path")
+	if aPath != bPath {
+		return nil, nil
+	}
+
+	same, err := sameExcept(a, b, "shardFunc")
+	if err != nil || !same {
+		return nil, err
+	}
+
+	aShard, _ := a.str("shardFunc")
+	bShard, _ := b.str("shardFunc")
+
+	return &Reshard{Path: aPath, From: aShard, To: bShard}, nil
+}
+
 type SimpleMount struct {
 	prefix ds.Key
 	diskId string
@@ -78,6 +173,36 @@ func (m *SimpleMounts) filter(filter SimpleMounts) SimpleMounts {
 	return out
 }
 
+// hasPath returns true if any of the mounts stores data in path
+func (m *SimpleMounts) hasPath(path string) bool {
+	for _, mnt := range *m {
+		if p, _ := mnt.spec.str("path"); p == path {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasUnder returns true if any of the mounts, except the ones in skip, is
+// mounted at prefix or below it
+func (m *SimpleMounts) hasUnder(prefix ds.Key, skip ...SimpleMount) bool {
+	for _, mnt := range *m {
+		skipped := false
+		for _, s := range skip {
+			if mnt.prefix.Equal(s.prefix) {
+				skipped = true
+			}
+		}
+
+		if !skipped && (mnt.prefix.Equal(prefix) || mnt.prefix.IsDescendantOf(prefix)) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (m *SimpleMounts) sort() {
 	sort.Slice(*m, func(i, j int) bool { return (*m)[i].prefix.String() > (*m)[j].prefix.String() })
 }
@@ -95,3 +220,47 @@ func (m *SimpleMounts) spec() Spec {
 		"mounts": mounts,
 	}
 }
+
+// MountInfo describes a single datastore mounted by a spec
+type MountInfo struct {
+	Prefix string
+	Type   string
+	Path   string
+}
+
+// Mounts lists datastores used by a spec. Transparent layers like measure or
+// log are skipped, non-mount specs are reported as a single mount at '/'
+func Mounts(specIn map[string]interface{}) ([]MountInfo, error) {
+	var spec Spec
+	spec, err := cleanUp(specIn)
+	if err != nil {
+		return nil, err
+	}
+
+	t, _ := spec.Type()
+	if t != "mount" {
+		path, _ := spec.str("path")
+		return []MountInfo{{Prefix: "/", Type: t, Path: path}}, nil
+	}
+
+	mounts, ok := spec["mounts"].([]interface{})
+	if !ok {
+		return nil, errors.New("'mounts' field is missing or not an array")
+	}
+
+	out := make([]MountInfo, 0, len(mounts))
+	for _, m := range mounts {
+		var mount Spec
+		mount, ok := m.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("'mounts' element is of invalid type")
+		}
+
+		t, _ := mount.Type()
+		prefix, _ := mount.str("mountpoint")
+		path, _ := mount.str("path")
+		out = append(out, MountInfo{Prefix: prefix, Type: t, Path: path})
+	}
+
+	return out, nil
+}